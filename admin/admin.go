@@ -0,0 +1,235 @@
+// Package admin provides an HTTP handler for operators to inspect the keyed
+// limiters, and to reset or override the limits of keys. All the responses
+// are in JSON:
+//
+//	GET    /limiters                                 list the limiters
+//	GET    /limiters/{limiter}/keys                  list the active keys
+//	GET    /limiters/{limiter}/keys/{key}            show the state of key
+//	DELETE /limiters/{limiter}/keys/{key}            reset key
+//	PUT    /limiters/{limiter}/keys/{key}/override   override the limit of key
+//	DELETE /limiters/{limiter}/keys/{key}/override   clear the override of key
+//
+// Keys containing "/" must be escaped in the paths, e.g. "a%2Fb".
+//
+// Resetting a key discards its limiter (while its override, if any, is kept).
+// For a synced key, the counts of its windows in the central datastore are
+// cleared as well, since otherwise the new limiter would catch up with them
+// after the next synchronization. If they can not be cleared (e.g. the key
+// is synced by a custom Synchronizer, or the datastore fails), the reset is
+// refused with 409 Conflict.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// Limiters provides the keyed limiters by name.
+type Limiters interface {
+	// Names returns the sorted names of all the limiters.
+	Names() []string
+
+	// Get returns the limiter named name, if any.
+	Get(name string) (*sw.KeyedLimiter, bool)
+}
+
+// Map is a fixed set of keyed limiters, keyed by name.
+type Map map[string]*sw.KeyedLimiter
+
+func (m Map) Names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m Map) Get(name string) (*sw.KeyedLimiter, bool) {
+	k, ok := m[name]
+	return k, ok
+}
+
+// Window is the state of a window.
+type Window struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// Key is the state of a key.
+type Key struct {
+	Key   string `json:"key"`
+	Limit int64  `json:"limit"`
+
+	// The approximate count of events during the sliding window.
+	Count int64 `json:"count"`
+
+	// The following fields are only shown for a single key.
+	Curr     *Window      `json:"curr,omitempty"`
+	Prev     *Window      `json:"prev,omitempty"`
+	Pending  *int64       `json:"pending,omitempty"` // The changes that have not been synced.
+	Override *sw.Override `json:"override,omitempty"`
+}
+
+// overrideRequest is the body of the override request.
+type overrideRequest struct {
+	Limit    int64  `json:"limit"`
+	Duration string `json:"duration"` // e.g. "10m"
+}
+
+// Handler serves the admin API.
+type Handler struct {
+	limiters Limiters
+}
+
+// NewHandler creates a new handler serving the admin API of limiters.
+func NewHandler(limiters Limiters) *Handler {
+	return &Handler{limiters: limiters}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Split the escaped path, since keys may contain "/".
+	var parts []string
+	for _, p := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		p, err := url.PathUnescape(p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts = append(parts, p)
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "limiters":
+		h.serveLimiters(w, r)
+	case len(parts) >= 3 && parts[0] == "limiters" && parts[2] == "keys":
+		k, ok := h.limiters.Get(parts[1])
+		if !ok {
+			http.Error(w, fmt.Sprintf("limiter %q not found", parts[1]), http.StatusNotFound)
+			return
+		}
+		switch {
+		case len(parts) == 3:
+			h.serveKeys(w, r, k)
+		case len(parts) == 4:
+			h.serveKey(w, r, k, parts[3])
+		case len(parts) == 5 && parts[4] == "override":
+			h.serveOverride(w, r, k, parts[3])
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveLimiters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, h.limiters.Names())
+}
+
+func (h *Handler) serveKeys(w http.ResponseWriter, r *http.Request, k *sw.KeyedLimiter) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	keys := []Key{}
+	for _, key := range k.Keys() {
+		lim, ok := k.Lookup(key)
+		if !ok {
+			continue // Removed in the meantime.
+		}
+		keys = append(keys, Key{Key: key, Limit: lim.Limit(), Count: lim.Count(now)})
+	}
+	writeJSON(w, keys)
+}
+
+func (h *Handler) serveKey(w http.ResponseWriter, r *http.Request, k *sw.KeyedLimiter, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		lim, ok := k.Lookup(key)
+		if !ok {
+			http.Error(w, fmt.Sprintf("key %q not found", key), http.StatusNotFound)
+			return
+		}
+
+		// Count advances the windows, so the state must be taken after it.
+		count := lim.Count(time.Now())
+		state := lim.Snapshot()
+		pending := lim.Pending()
+
+		resp := Key{
+			Key:     key,
+			Limit:   lim.Limit(),
+			Count:   count,
+			Curr:    &Window{Start: time.Unix(0, state.CurrStart), Count: state.CurrCount},
+			Prev:    &Window{Start: time.Unix(0, state.PrevStart), Count: state.PrevCount},
+			Pending: &pending,
+		}
+		for _, o := range k.Overrides() {
+			if o.Key == key {
+				o := o
+				resp.Override = &o
+			}
+		}
+		writeJSON(w, resp)
+	case http.MethodDelete:
+		// The counts in the central datastore may exist even if the key
+		// is not active locally, so a limiter is needed to clear them.
+		lim, existed := k.Lookup(key)
+		if !existed {
+			lim = k.Limiter(key)
+		}
+		if err := lim.ClearStore(time.Now()); err != nil {
+			if !existed {
+				k.Remove(key)
+			}
+			http.Error(w, fmt.Sprintf("key %q can not be reset: %v", key, err), http.StatusConflict)
+			return
+		}
+		k.Remove(key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) serveOverride(w http.ResponseWriter, r *http.Request, k *sw.KeyedLimiter, key string) {
+	switch r.Method {
+	case http.MethodPut:
+		var req overrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || req.Limit < 0 {
+			http.Error(w, "limit must not be negative, and duration must be positive", http.StatusBadRequest)
+			return
+		}
+		k.Override(key, req.Limit, d)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		k.ClearOverride(key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) // nolint:errcheck
+}
@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/httpstore"
+)
+
+func TestHandler(t *testing.T) {
+	store := httpstore.NewMemStore()
+	k := sw.NewKeyedLimiter(time.Hour, 10, func(key string) (sw.Window, sw.StopFunc) {
+		return sw.NewSyncWindow(key, sw.NewBlockingSynchronizer(store, time.Hour))
+	})
+	defer k.Stop()
+
+	now := time.Now()
+	k.AllowN("a/b", now, 3) // synced
+	k.AllowN("a/b", now, 2) // pending
+	k.AllowN("c", now, 1)
+
+	h := NewHandler(Map{"api": k})
+	do := func(method, path, body string, wantCode int, resp interface{}) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if w.Code != wantCode {
+			t.Fatalf("%s %s: got code %d, want: %d (%s)", method, path, w.Code, wantCode, w.Body)
+		}
+		if resp != nil {
+			if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+				t.Fatalf("%s %s: err: %v", method, path, err)
+			}
+		}
+	}
+
+	var names []string
+	do(http.MethodGet, "/limiters", "", http.StatusOK, &names)
+	if !reflect.DeepEqual(names, []string{"api"}) {
+		t.Errorf("limiters: got %v, want: [api]", names)
+	}
+
+	var keys []Key
+	do(http.MethodGet, "/limiters/api/keys", "", http.StatusOK, &keys)
+	want := []Key{{Key: "a/b", Limit: 10, Count: 5}, {Key: "c", Limit: 10, Count: 1}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys: got %+v, want: %+v", keys, want)
+	}
+
+	do(http.MethodPut, "/limiters/api/keys/a%2Fb/override", `{"limit": 20, "duration": "1h"}`, http.StatusNoContent, nil)
+
+	var key Key
+	do(http.MethodGet, "/limiters/api/keys/a%2Fb", "", http.StatusOK, &key)
+	start := now.Truncate(time.Hour)
+	if key.Limit != 20 || key.Count != 5 || key.Curr == nil || !key.Curr.Start.Equal(start) ||
+		key.Curr.Count != 5 || key.Pending == nil || *key.Pending != 2 ||
+		key.Override == nil || key.Override.Limit != 20 {
+		t.Errorf("key: got %+v", key)
+	}
+
+	do(http.MethodDelete, "/limiters/api/keys/a%2Fb/override", "", http.StatusNoContent, nil)
+	do(http.MethodDelete, "/limiters/api/keys/a%2Fb", "", http.StatusNoContent, nil)
+	if count, _ := store.Get("a/b", start.UnixNano()); count != 0 {
+		t.Errorf("store.Get(a/b): got %d, want: 0", count)
+	}
+	if _, ok := k.Lookup("a/b"); ok {
+		t.Errorf("Lookup(a/b): got true, want: false")
+	}
+
+	// The counts of the keys not active locally are cleared too.
+	store.Add("d", start.UnixNano(), 4) // nolint:errcheck
+	do(http.MethodDelete, "/limiters/api/keys/d", "", http.StatusNoContent, nil)
+	if count, _ := store.Get("d", start.UnixNano()); count != 0 {
+		t.Errorf("store.Get(d): got %d, want: 0", count)
+	}
+	if _, ok := k.Lookup("d"); ok {
+		t.Errorf("Lookup(d): got true, want: false")
+	}
+
+	do(http.MethodGet, "/limiters/api/keys/a%2Fb", "", http.StatusNotFound, nil)
+	do(http.MethodGet, "/limiters/web/keys", "", http.StatusNotFound, nil)
+	do(http.MethodPut, "/limiters/api/keys/c/override", `{"limit": 1, "duration": "forever"}`, http.StatusBadRequest, nil)
+	do(http.MethodPost, "/limiters/api/keys/c", "", http.StatusMethodNotAllowed, nil)
+	do(http.MethodGet, "/unknown", "", http.StatusNotFound, nil)
+}
+
+func TestHandler_ResetUnclearable(t *testing.T) {
+	store := httpstore.NewMemStore()
+	k := sw.NewKeyedLimiter(time.Hour, 10, func(key string) (sw.Window, sw.StopFunc) {
+		// Hide the ability of the synchronizer to clear the counts.
+		syncer := struct{ sw.Synchronizer }{sw.NewBlockingSynchronizer(store, 0)}
+		return sw.NewSyncWindow(key, syncer)
+	})
+	defer k.Stop()
+	k.AllowN("a", time.Now(), 3)
+
+	h := NewHandler(Map{"api": k})
+	for _, key := range []string{"a", "b"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/limiters/api/keys/"+key, nil))
+		if w.Code != http.StatusConflict {
+			t.Errorf("DELETE %s: got code %d, want: %d", key, w.Code, http.StatusConflict)
+		}
+	}
+
+	// The refused key is kept, and no key is created.
+	if keys := k.Keys(); !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("keys: got %v, want: [a]", keys)
+	}
+}
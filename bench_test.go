@@ -0,0 +1,163 @@
+package slidingwindow
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The benchmarks are named in the form of "Benchmark<Type>_<Window>_<Method>"
+// with "key=value" sub-benchmarks, so that their results can be compared
+// by benchstat directly:
+//
+//     $ go test -run='^$' -bench=. -benchmem -count=10 > old.txt
+//     $ # make some changes
+//     $ go test -run='^$' -bench=. -benchmem -count=10 > new.txt
+//     $ benchstat old.txt new.txt
+//
+// Contention can be observed by running the parallel benchmarks with
+// different numbers of CPUs (e.g. -cpu=1,8,32), on a host with at least as
+// many cores. Otherwise the goroutines are merely time-sliced on the same
+// cores, and the results say nothing about contention.
+//
+// Note that AllowN with LocalWindow is expected to make zero allocations,
+// which is guarded by TestLimiter_LocalWindow_AllowN_Allocs.
+
+// benchLimit is large enough to ensure that all the events are permitted,
+// so that the benchmarks measure the cost of the permitted path.
+const benchLimit = int64(1) << 62
+
+func newBenchLocalWindow() (Window, StopFunc) {
+	return NewLocalWindow()
+}
+
+func BenchmarkLimiter_LocalWindow_AllowN(b *testing.B) {
+	lim, _ := NewLimiter(time.Second, benchLimit, newBenchLocalWindow)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lim.AllowN(time.Now(), 1)
+	}
+}
+
+func BenchmarkLimiter_LocalWindow_AllowN_Parallel(b *testing.B) {
+	lim, _ := NewLimiter(time.Second, benchLimit, newBenchLocalWindow)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lim.AllowN(time.Now(), 1)
+		}
+	})
+}
+
+func BenchmarkLimiter_ShardedWindow_AllowN_Parallel(b *testing.B) {
+	lim, _ := NewLimiter(time.Second, benchLimit, func() (Window, StopFunc) {
+		return NewShardedWindow(0)
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lim.AllowN(time.Now(), 1)
+		}
+	})
+}
+
+func BenchmarkLimiter_SyncWindow_AllowN(b *testing.B) {
+	syncers := []struct {
+		name      string
+		newSyncer func(store Datastore) Synchronizer
+	}{
+		{
+			"sync=blocking",
+			func(store Datastore) Synchronizer {
+				return NewBlockingSynchronizer(store, 100*time.Millisecond)
+			},
+		},
+		{
+			"sync=nonblocking",
+			func(store Datastore) Synchronizer {
+				return NewNonblockingSynchronizer(store, 100*time.Millisecond)
+			},
+		},
+	}
+
+	for _, s := range syncers {
+		s := s
+		b.Run(s.name, func(b *testing.B) {
+			store := newMemDatastore()
+			lim, stop := NewLimiter(time.Second, benchLimit, func() (Window, StopFunc) {
+				return NewSyncWindow("test", s.newSyncer(store))
+			})
+			defer stop()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lim.AllowN(time.Now(), 1)
+			}
+		})
+
+		b.Run(s.name+"/parallel", func(b *testing.B) {
+			store := newMemDatastore()
+			lim, stop := NewLimiter(time.Second, benchLimit, func() (Window, StopFunc) {
+				return NewSyncWindow("test", s.newSyncer(store))
+			})
+			defer stop()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					lim.AllowN(time.Now(), 1)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkKeyedLimiter_LocalWindow_AllowN(b *testing.B) {
+	for _, n := range []int{1, 100, 10000} {
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = "key-" + strconv.Itoa(i)
+		}
+
+		b.Run("keys="+strconv.Itoa(n), func(b *testing.B) {
+			k := NewKeyedLimiter(time.Second, benchLimit, func(key string) (Window, StopFunc) {
+				return NewLocalWindow()
+			})
+			defer k.Stop()
+
+			// Create all the limiters in advance.
+			for _, key := range keys {
+				k.Limiter(key)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					k.AllowN(keys[i%len(keys)], time.Now(), 1)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestLimiter_LocalWindow_AllowN_Allocs(t *testing.T) {
+	lim, _ := NewLimiter(time.Second, benchLimit, newBenchLocalWindow)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		lim.AllowN(time.Now(), 1)
+	})
+	if allocs != 0 {
+		t.Errorf("allocs per AllowN = %v, want: 0", allocs)
+	}
+}
@@ -0,0 +1,153 @@
+package slidingwindow
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// Boundary represents the policy that decides the boundaries of windows.
+type Boundary interface {
+	// Truncate returns the start boundary of the window that t falls into.
+	Truncate(t time.Time) time.Time
+
+	// Next returns the start boundary of the window right after the one
+	// that starts at start.
+	Next(start time.Time) time.Time
+}
+
+// fixedBoundary aligns windows to the multiples of a fixed duration since
+// the zero time, which is the boundary policy used by NewLimiter.
+type fixedBoundary struct {
+	size time.Duration
+}
+
+// Every returns a boundary policy whose windows all have the fixed size.
+func Every(size time.Duration) Boundary {
+	return fixedBoundary{size: size}
+}
+
+func (b fixedBoundary) Truncate(t time.Time) time.Time {
+	return t.Truncate(b.size)
+}
+
+func (b fixedBoundary) Next(start time.Time) time.Time {
+	return start.Add(b.size)
+}
+
+// calendarBoundary aligns windows to the calendar in a given location.
+//
+// Since the boundaries are computed by time.Date, which normalizes the wall
+// clock within loc, the windows containing DST transitions are simply
+// shorter or longer than the others (e.g. a day of 23 or 25 hours).
+type calendarBoundary struct {
+	loc *time.Location
+
+	// truncate returns the calendar date (in loc) that the window containing
+	// t starts at.
+	truncate func(t time.Time) (year int, month time.Month, day int)
+
+	// years, months and days are the calendar distance between two adjacent
+	// windows.
+	years, months, days int
+}
+
+// Daily returns a boundary policy whose windows are calendar days in loc.
+func Daily(loc *time.Location) Boundary {
+	return calendarBoundary{
+		loc: loc,
+		truncate: func(t time.Time) (int, time.Month, int) {
+			return t.Date()
+		},
+		days: 1,
+	}
+}
+
+// Weekly returns a boundary policy whose windows are calendar weeks in loc,
+// each of which begins on weekday.
+func Weekly(loc *time.Location, weekday time.Weekday) Boundary {
+	return calendarBoundary{
+		loc: loc,
+		truncate: func(t time.Time) (int, time.Month, int) {
+			year, month, day := t.Date()
+			offset := (int(t.Weekday()) - int(weekday) + 7) % 7
+			return year, month, day - offset
+		},
+		days: 7,
+	}
+}
+
+// Monthly returns a boundary policy whose windows are calendar months in loc.
+func Monthly(loc *time.Location) Boundary {
+	return calendarBoundary{
+		loc: loc,
+		truncate: func(t time.Time) (int, time.Month, int) {
+			year, month, _ := t.Date()
+			return year, month, 1
+		},
+		months: 1,
+	}
+}
+
+func (b calendarBoundary) Truncate(t time.Time) time.Time {
+	year, month, day := b.truncate(t.In(b.loc))
+	return time.Date(year, month, day, 0, 0, 0, 0, b.loc)
+}
+
+func (b calendarBoundary) Next(start time.Time) time.Time {
+	year, month, day := start.In(b.loc).Date()
+	return time.Date(year+b.years, month+time.Month(b.months), day+b.days, 0, 0, 0, 0, b.loc)
+}
+
+// offsetBoundary shifts all the boundaries of another policy by a phase.
+type offsetBoundary struct {
+	boundary Boundary
+	offset   time.Duration
+}
+
+// Offset returns a boundary policy whose boundaries are the ones of boundary
+// shifted by offset, so that limiters with different offsets do not roll
+// over their windows at the same time.
+//
+// Since the limiter passes the start boundaries to its windows, the start
+// keys of SyncWindow are shifted consistently.
+func Offset(boundary Boundary, offset time.Duration) Boundary {
+	return offsetBoundary{boundary: boundary, offset: offset}
+}
+
+func (b offsetBoundary) Truncate(t time.Time) time.Time {
+	return b.boundary.Truncate(t.Add(-b.offset)).Add(b.offset)
+}
+
+func (b offsetBoundary) Next(start time.Time) time.Time {
+	return b.boundary.Next(start.Add(-b.offset)).Add(b.offset)
+}
+
+// resize returns a boundary policy of the same type as boundary, whose
+// windows are of the given size instead. It reports false if the window
+// sizes of boundary are decided by the calendar.
+func resize(boundary Boundary, size time.Duration) (Boundary, bool) {
+	switch b := boundary.(type) {
+	case fixedBoundary:
+		return Every(size), true
+	case offsetBoundary:
+		inner, ok := resize(b.boundary, size)
+		if !ok {
+			return nil, false
+		}
+		return Offset(inner, b.offset), true
+	default:
+		return nil, false
+	}
+}
+
+// KeyOffset returns a deterministic offset within [0, size) derived from
+// the hash of key, which is suitable for spreading the rollovers of
+// different keys by Offset.
+func KeyOffset(key string, size time.Duration) time.Duration {
+	if size <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key)) // nolint:errcheck
+	return time.Duration(h.Sum64() % uint64(size))
+}
@@ -0,0 +1,201 @@
+package slidingwindow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoundary(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("err: %v", err)
+	}
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, loc)
+	}
+
+	cases := []struct {
+		name      string
+		boundary  Boundary
+		t         time.Time
+		wantStart time.Time
+		wantNext  time.Time
+	}{
+		{
+			name:      "every",
+			boundary:  Every(time.Hour),
+			t:         date(time.March, 8, 5).Add(30 * time.Minute),
+			wantStart: date(time.March, 8, 5),
+			wantNext:  date(time.March, 8, 6),
+		},
+		{
+			name:      "every-offset",
+			boundary:  Offset(Every(time.Hour), 15*time.Minute),
+			t:         date(time.March, 8, 5),
+			wantStart: date(time.March, 8, 4).Add(15 * time.Minute),
+			wantNext:  date(time.March, 8, 5).Add(15 * time.Minute),
+		},
+		{
+			name:      "daily-offset",
+			boundary:  Offset(Daily(loc), 6*time.Hour),
+			t:         date(time.January, 15, 5),
+			wantStart: date(time.January, 14, 6),
+			wantNext:  date(time.January, 15, 6),
+		},
+		{
+			name:      "daily",
+			boundary:  Daily(loc),
+			t:         date(time.January, 15, 23),
+			wantStart: date(time.January, 15, 0),
+			wantNext:  date(time.January, 16, 0),
+		},
+		{
+			name:      "daily-dst-start", // a day of 23 hours
+			boundary:  Daily(loc),
+			t:         date(time.March, 8, 12),
+			wantStart: date(time.March, 8, 0),
+			wantNext:  date(time.March, 8, 0).Add(23 * time.Hour),
+		},
+		{
+			name:      "daily-dst-end", // a day of 25 hours
+			boundary:  Daily(loc),
+			t:         date(time.November, 1, 12),
+			wantStart: date(time.November, 1, 0),
+			wantNext:  date(time.November, 1, 0).Add(25 * time.Hour),
+		},
+		{
+			name:      "weekly",
+			boundary:  Weekly(loc, time.Monday),
+			t:         date(time.March, 1, 12), // Sunday
+			wantStart: date(time.February, 23, 0),
+			wantNext:  date(time.March, 2, 0),
+		},
+		{
+			name:      "monthly",
+			boundary:  Monthly(loc),
+			t:         date(time.December, 31, 23),
+			wantStart: date(time.December, 1, 0),
+			wantNext:  time.Date(2027, time.January, 1, 0, 0, 0, 0, loc),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start := c.boundary.Truncate(c.t)
+			if !start.Equal(c.wantStart) {
+				t.Errorf("Truncate(%v) = %v, want: %v", c.t, start, c.wantStart)
+			}
+			next := c.boundary.Next(start)
+			if !next.Equal(c.wantNext) {
+				t.Errorf("Next(%v) = %v, want: %v", start, next, c.wantNext)
+			}
+		})
+	}
+}
+
+func TestKeyOffset(t *testing.T) {
+	keys := []string{"a", "b", "test", "user:1", "user:2"}
+	spread := make(map[time.Duration]bool)
+
+	for _, key := range keys {
+		offset := KeyOffset(key, size)
+		if offset < 0 || offset >= size {
+			t.Errorf("KeyOffset(%q, %v) = %v, want: within [0, %v)", key, size, offset, size)
+		}
+		if again := KeyOffset(key, size); again != offset {
+			t.Errorf("KeyOffset(%q, %v) = %v, want: %v", key, size, again, offset)
+		}
+		spread[offset] = true
+	}
+
+	if len(spread) != len(keys) {
+		t.Errorf("got %d distinct offsets, want: %d", len(spread), len(keys))
+	}
+}
+
+func TestLimiter_Offset_SyncWindow_AllowN(t *testing.T) {
+	store := newMemDatastore()
+	offset := 3 * d
+	lim, stop := NewBoundaryLimiter(Offset(Every(size), offset), limit, func() (Window, StopFunc) {
+		return NewSyncWindow("test", NewBlockingSynchronizer(store, 0))
+	})
+	defer stop()
+
+	cases := []caseArg{
+		// prev-window: empty, count: 0
+		// curr-window: [t3 - 1s, t3), count: 0
+		{t0, 4, true},
+		{t2, 6, true},
+
+		// prev-window: [t3 - 1s, t3), count: 10
+		// curr-window: [t3, t13), count: 0
+		{t3, 1, false},
+		{t10, 3, true}, // count will be (3/10*10 + 3) = 6
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := lim.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("lim.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+
+	// The counts are synced to the datastore with the shifted start keys.
+	for _, w := range []struct {
+		start time.Time
+		count int64
+	}{
+		{t3.Add(-size), 10},
+		{t3, 3},
+	} {
+		count, _ := store.Get("test", w.start.UnixNano())
+		if count != w.count {
+			t.Errorf("store.Get(%v) = %d, want: %d", w.start, count, w.count)
+		}
+	}
+}
+
+func TestLimiter_Monthly_AllowN(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("err: %v", err)
+	}
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 0, 0, 0, 0, loc)
+	}
+
+	lim, _ := NewBoundaryLimiter(Monthly(loc), 30, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+
+	cases := []caseArg{
+		// prev-window: empty, count: 0
+		// curr-window: [Apr 1, May 1), count: 0
+		{date(time.April, 1), 20, true},
+		{date(time.April, 30), 10, true},
+		{date(time.April, 30), 1, false},
+
+		// prev-window: [Apr 1, May 1), count: 30
+		// curr-window: [May 1, Jun 1), count: 0
+		{date(time.May, 1), 1, false},
+		{date(time.May, 11), 10, true}, // count will be (21/31*30 + 10) ≈ 30
+		{date(time.May, 11), 1, false},
+
+		// prev-window: [Jun 1, Jul 1), count: 0
+		// curr-window: [Jul 1, Aug 1), count: 0
+		{date(time.July, 1), 30, true},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := lim.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("lim.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+}
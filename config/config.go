@@ -0,0 +1,258 @@
+// Package config parses the declarative definitions of rate-limit policies,
+// builds keyed limiters from them, and applies the updates of the
+// definitions in place.
+//
+// The definitions are written in JSON, where a policy is either a rate in
+// the shorthand form "limit/size", or a structured form with the backend
+// and the sync settings:
+//
+//	{
+//	    "backends": {
+//	        "redis": {"type": "redis", "addr": "localhost:6379"}
+//	    },
+//	    "policies": {
+//	        "search": "100/1m",
+//	        "login": {
+//	            "rate": "5/1m",
+//	            "key": "{user}",
+//	            "backend": "redis",
+//	            "synchronizer": "nonblocking",
+//	            "sync_interval": "200ms"
+//	        }
+//	    }
+//	}
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// File is the content of a config file.
+type File struct {
+	// The named backends, i.e. the central datastores, that the policies
+	// sync their counts through.
+	Backends map[string]Backend `json:"backends"`
+
+	// The named policies.
+	Policies map[string]Policy `json:"policies"`
+}
+
+// Backend is the definition of a backend.
+type Backend struct {
+	// The type of the backend, e.g. "redis", "http" or "grpc", which is
+	// interpreted by the user of the package.
+	Type string `json:"type"`
+
+	// The address of the backend, e.g. the URL of an HTTP store.
+	Addr string `json:"addr"`
+
+	// The credential of the backend, if required.
+	Token string `json:"token"`
+}
+
+// The types of synchronizers.
+const (
+	Blocking    = "blocking"
+	Nonblocking = "nonblocking"
+)
+
+// Policy is the definition of a policy.
+type Policy struct {
+	// The maximum events permitted to happen during one window size.
+	Limit int64
+	Size  time.Duration
+
+	// The template of the keys that the events are counted by, which is
+	// interpreted by the user of the package, e.g. "{user}".
+	Key string
+
+	// The name of the backend. Empty backend means counting locally.
+	Backend string
+
+	// The type of the synchronizer (Blocking by default), and the sync
+	// interval (DefaultSyncInterval by default), which only make sense if
+	// Backend is not empty.
+	Synchronizer string
+	SyncInterval time.Duration
+}
+
+// DefaultSyncInterval is the sync interval of the policies with a backend
+// but without one, since a zero interval means syncing on every decision,
+// i.e. one round trip to the central datastore per decision.
+const DefaultSyncInterval = 100 * time.Millisecond
+
+// UnmarshalJSON parses either the shorthand form, or the structured form
+// of a policy.
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	var rate string
+	if err := json.Unmarshal(b, &rate); err == nil {
+		limit, size, err := ParseRate(rate)
+		if err != nil {
+			return err
+		}
+		*p = Policy{Limit: limit, Size: size}
+		return nil
+	}
+
+	var v struct {
+		Rate         string   `json:"rate"`
+		Limit        int64    `json:"limit"`
+		Size         duration `json:"size"`
+		Key          string   `json:"key"`
+		Backend      string   `json:"backend"`
+		Synchronizer string   `json:"synchronizer"`
+		SyncInterval duration `json:"sync_interval"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	*p = Policy{
+		Limit:        v.Limit,
+		Size:         time.Duration(v.Size),
+		Key:          v.Key,
+		Backend:      v.Backend,
+		Synchronizer: v.Synchronizer,
+		SyncInterval: time.Duration(v.SyncInterval),
+	}
+	if v.Rate != "" {
+		if v.Limit != 0 || v.Size != 0 {
+			return fmt.Errorf("rate %q conflicts with limit and size", v.Rate)
+		}
+		var err error
+		if p.Limit, p.Size, err = ParseRate(v.Rate); err != nil {
+			return err
+		}
+	}
+	return p.validate()
+}
+
+func (p *Policy) validate() error {
+	if p.Limit <= 0 || p.Size <= 0 {
+		return fmt.Errorf("limit and size must be positive")
+	}
+	switch p.Synchronizer {
+	case "", Blocking, Nonblocking:
+	default:
+		return fmt.Errorf("unknown synchronizer %q", p.Synchronizer)
+	}
+	if p.SyncInterval < 0 {
+		return fmt.Errorf("sync interval must not be negative")
+	}
+	if p.Backend != "" && p.SyncInterval == 0 {
+		p.SyncInterval = DefaultSyncInterval
+	}
+	return nil
+}
+
+// syncs reports whether p syncs its counts in the same way as other.
+func (p Policy) syncs(other Policy) bool {
+	return p.Backend == other.Backend &&
+		p.Synchronizer == other.Synchronizer &&
+		p.SyncInterval == other.SyncInterval
+}
+
+// NewKeyedWindow returns the function creating the windows of the policy
+// named name, which sync their counts to store. The keys in store are
+// namespaced by name, e.g. "name:key". If store is nil, the windows are
+// local ones.
+func (p Policy) NewKeyedWindow(name string, store sw.Datastore) sw.NewKeyedWindow {
+	if store == nil {
+		return func(key string) (sw.Window, sw.StopFunc) {
+			return sw.NewLocalWindow()
+		}
+	}
+
+	return func(key string) (sw.Window, sw.StopFunc) {
+		var syncer sw.Synchronizer
+		if p.Synchronizer == Nonblocking {
+			syncer = sw.NewNonblockingSynchronizer(store, p.SyncInterval)
+		} else {
+			syncer = sw.NewBlockingSynchronizer(store, p.SyncInterval)
+		}
+		return sw.NewSyncWindow(name+":"+key, syncer)
+	}
+}
+
+// ParseRate parses the rate in the shorthand form "limit/size", e.g.
+// "100/1m", where the number of size may be omitted if it's 1, e.g. "100/m".
+func ParseRate(s string) (limit int64, size time.Duration, err error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return 0, 0, fmt.Errorf("rate %q: missing '/'", s)
+	}
+
+	limit, err = strconv.ParseInt(s[:i], 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, 0, fmt.Errorf("rate %q: bad limit", s)
+	}
+
+	unit := s[i+1:]
+	if unit != "" && (unit[0] < '0' || unit[0] > '9') {
+		unit = "1" + unit
+	}
+	size, err = time.ParseDuration(unit)
+	if err != nil || size <= 0 {
+		return 0, 0, fmt.Errorf("rate %q: bad size", s)
+	}
+	return limit, size, nil
+}
+
+// duration is a time.Duration encoded as a string, e.g. "1m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// Parse parses the content of a config file.
+func Parse(data []byte) (*File, error) {
+	var f File
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+
+	for name, p := range f.Policies {
+		if p.Backend == "" {
+			continue
+		}
+		if _, ok := f.Backends[p.Backend]; !ok {
+			return nil, fmt.Errorf("policy %q: unknown backend %q", name, p.Backend)
+		}
+	}
+	return &f, nil
+}
+
+// Load loads the config file at path.
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %v", path, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/httpstore"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := []struct {
+		in    string
+		limit int64
+		size  time.Duration
+		err   bool
+	}{
+		{"100/1m", 100, time.Minute, false},
+		{"100/m", 100, time.Minute, false},
+		{"5/s", 5, time.Second, false},
+		{"10/1h30m", 10, 90 * time.Minute, false},
+		{"100", 0, 0, true},
+		{"0/1m", 0, 0, true},
+		{"x/1m", 0, 0, true},
+		{"100/", 0, 0, true},
+		{"100/0s", 0, 0, true},
+		{"100/fortnight", 0, 0, true},
+	}
+	for _, c := range cases {
+		limit, size, err := ParseRate(c.in)
+		if limit != c.limit || size != c.size || (err != nil) != c.err {
+			t.Errorf("ParseRate(%q) = (%d, %v, %v), want: (%d, %v, err: %v)",
+				c.in, limit, size, err, c.limit, c.size, c.err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(`{
+		"backends": {
+			"redis": {"type": "redis", "addr": "localhost:6379"}
+		},
+		"policies": {
+			"search": "100/1m",
+			"login": {
+				"rate": "5/1m",
+				"key": "{user}",
+				"backend": "redis",
+				"synchronizer": "nonblocking",
+				"sync_interval": "200ms"
+			},
+			"upload": {"limit": 10, "size": "1h"},
+			"export": {"rate": "1/1h", "backend": "redis"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	want := &File{
+		Backends: map[string]Backend{
+			"redis": {Type: "redis", Addr: "localhost:6379"},
+		},
+		Policies: map[string]Policy{
+			"search": {Limit: 100, Size: time.Minute},
+			"login": {
+				Limit:        5,
+				Size:         time.Minute,
+				Key:          "{user}",
+				Backend:      "redis",
+				Synchronizer: Nonblocking,
+				SyncInterval: 200 * time.Millisecond,
+			},
+			"upload": {Limit: 10, Size: time.Hour},
+			"export": {
+				Limit:        1,
+				Size:         time.Hour,
+				Backend:      "redis",
+				SyncInterval: DefaultSyncInterval,
+			},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("Parse() = %+v, want: %+v", f, want)
+	}
+
+	for _, in := range []string{
+		`{"policies": {"a": "100"}}`,
+		`{"policies": {"a": {"rate": "1/s", "limit": 1}}}`,
+		`{"policies": {"a": {"limit": 1}}}`,
+		`{"policies": {"a": {"rate": "1/s", "synchronizer": "eventual"}}}`,
+		`{"policies": {"a": {"rate": "1/s", "burst": 1}}}`,
+		`{"policies": {"a": {"rate": "1/s", "backend": "redis"}}}`,
+		`{"policies": {"a": {"rate": "1/s", "sync_interval": "-1s"}}}`,
+	} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%s): got nil err", in)
+		}
+	}
+}
+
+func TestSet_Apply(t *testing.T) {
+	store := httpstore.NewMemStore()
+	set := NewSet(map[string]sw.Datastore{"mem": store})
+	defer set.Stop()
+
+	now := time.Now()
+	allow := func(name string, n int64) bool {
+		_, lim, ok := set.Get(name)
+		if !ok {
+			t.Fatalf("set.Get(%q): not found", name)
+		}
+		return lim.AllowN("k", now, n)
+	}
+
+	if err := set.Apply(map[string]Policy{
+		"a": {Limit: 10, Size: time.Hour},
+		"b": {Limit: 10, Size: time.Hour, Backend: "mem", SyncInterval: time.Hour},
+		"c": {Limit: 10, Size: time.Hour},
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, a, _ := set.Get("a")
+	if !allow("a", 8) || !allow("b", 8) {
+		t.Fatal("allow: got false, want: true")
+	}
+
+	// a is updated in place with its count, b is replaced after flushing,
+	// and c is removed.
+	if err := set.Apply(map[string]Policy{
+		"a": {Limit: 20, Size: time.Hour},
+		"b": {Limit: 10, Size: time.Hour, Backend: "mem", SyncInterval: time.Minute},
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, lim, _ := set.Get("a"); lim != a || lim.Limit() != 20 {
+		t.Errorf("a: got (%p, %d), want: (%p, 20)", lim, lim.Limit(), a)
+	}
+	if ok := allow("a", 13); ok {
+		t.Errorf("allow(a, 13): got true, want: false")
+	}
+	allow("b", 0) // The new limiter of b has not synced the count yet.
+	if ok := allow("b", 3); ok {
+		t.Errorf("allow(b, 3): got true, want: false")
+	}
+	if names := set.Names(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("set.Names() = %v, want: [a b]", names)
+	}
+
+	if err := set.Apply(map[string]Policy{
+		"d": {Limit: 10, Size: time.Hour, Backend: "redis"},
+	}); err == nil {
+		t.Errorf("err: got nil, want: unknown backend")
+	}
+	if names := set.Names(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("set.Names() = %v, want: [a b]", names)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	write(`{"policies": {"a": "10/1m"}}`)
+
+	var mu sync.Mutex
+	var applied []int64
+	w, err := Watch(path, 10*time.Millisecond, func(f *File) error {
+		mu.Lock()
+		defer mu.Unlock()
+		applied = append(applied, f.Policies["a"].Limit)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer w.Stop()
+
+	write(`{"policies": {"a": "invalid"}}`)
+	time.Sleep(50 * time.Millisecond)
+	write(`{"policies": {"a": "20/1m"}}`)
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(applied, []int64{10, 20}) {
+		t.Errorf("applied = %v, want: [10 20]", applied)
+	}
+}
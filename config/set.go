@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+type entry struct {
+	policy Policy
+	lim    *sw.KeyedLimiter
+}
+
+// Set is a set of keyed limiters built from the named policies, which
+// applies the updates of the policies in place.
+type Set struct {
+	stores map[string]sw.Datastore
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+}
+
+// NewSet creates a new empty set, whose limiters sync their counts to the
+// stores named by the backends of the policies.
+func NewSet(stores map[string]sw.Datastore) *Set {
+	return &Set{
+		stores:  stores,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Validate reports an error if the given policies cannot be applied to
+// the set, e.g. because some of them refer to unknown backends.
+func (s *Set) Validate(policies map[string]Policy) error {
+	for name, p := range policies {
+		if p.Backend == "" {
+			continue
+		}
+		if _, ok := s.stores[p.Backend]; !ok {
+			return fmt.Errorf("policy %q: unknown backend %q", name, p.Backend)
+		}
+	}
+	return nil
+}
+
+// Apply updates the set to have exactly the given policies.
+//
+// The limiters of the existing policies are updated in place by SetLimit
+// and SetSize, without losing their current counts. However, if the way of
+// syncing (i.e. the backend or the sync settings) of a policy is changed,
+// its limiters are replaced with new ones after syncing the pending changes,
+// so the counts are kept only in the central datastore. The limiters of the
+// removed policies are stopped.
+func (s *Set) Apply(policies map[string]Policy) error {
+	// Validate the policies before any change.
+	if err := s.Validate(policies); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	var stale []*sw.KeyedLimiter
+	for name, e := range s.entries {
+		if _, ok := policies[name]; !ok {
+			stale = append(stale, e.lim)
+			delete(s.entries, name)
+		}
+	}
+	for name, p := range policies {
+		e, ok := s.entries[name]
+		if ok && e.policy.syncs(p) {
+			if p.Size != e.policy.Size {
+				e.lim.SetSize(p.Size)
+				e.lim.SetEvictInterval(p.Size)
+			}
+			if p.Limit != e.policy.Limit {
+				e.lim.SetLimit(p.Limit)
+			}
+			e.policy = p
+			continue
+		}
+
+		if ok {
+			stale = append(stale, e.lim)
+		}
+		lim := sw.NewKeyedLimiter(p.Size, p.Limit, p.NewKeyedWindow(name, s.stores[p.Backend]))
+		lim.SetEvictInterval(p.Size)
+		s.entries[name] = &entry{policy: p, lim: lim}
+	}
+	s.mu.Unlock()
+
+	for _, lim := range stale {
+		lim.Flush()
+		lim.Stop()
+	}
+	return nil
+}
+
+// Get returns the policy named name and its keyed limiter, if any.
+func (s *Set) Get(name string) (Policy, *sw.KeyedLimiter, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[name]
+	if !ok {
+		return Policy{}, nil, false
+	}
+	return e.policy, e.lim, true
+}
+
+// Names returns the sorted names of all the policies.
+func (s *Set) Names() []string {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Stop syncs the pending changes of all the limiters, and then stops them.
+func (s *Set) Stop() {
+	s.mu.Lock()
+	entries := s.entries
+	s.entries = make(map[string]*entry)
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		e.lim.Flush()
+		e.lim.Stop()
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+// Watcher watches a config file, and applies it whenever it changes.
+//
+// The file is polled rather than watched by filesystem notifications,
+// which works the same on all platforms, and also with the files replaced
+// by renaming (e.g. the ConfigMaps of Kubernetes).
+type Watcher struct {
+	path     string
+	interval time.Duration
+	apply    func(*File) error
+
+	data []byte // The content that has been loaded.
+
+	stopC chan struct{}
+	exitC chan struct{}
+}
+
+// Watch loads and applies the config file at path, and then polls it for
+// changes every interval. The changed file is applied only if it's valid,
+// otherwise the error is logged and the previous config remains in effect.
+func Watch(path string, interval time.Duration, apply func(*File) error) (*Watcher, error) {
+	w := &Watcher{
+		path:     path,
+		interval: interval,
+		apply:    apply,
+		stopC:    make(chan struct{}),
+		exitC:    make(chan struct{}),
+	}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	go w.watchLoop()
+	return w, nil
+}
+
+// Stop stops watching, and waits for the watching goroutine to exit.
+func (w *Watcher) Stop() {
+	close(w.stopC)
+	<-w.exitC
+}
+
+func (w *Watcher) watchLoop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.reload(); err != nil {
+				log.Printf("err: %v\n", err)
+			}
+		case <-w.stopC:
+			close(w.exitC)
+			return
+		}
+	}
+}
+
+// reload applies the config file, if it has changed since the last time
+// that it was applied.
+func (w *Watcher) reload() error {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if w.data != nil && bytes.Equal(data, w.data) {
+		return nil
+	}
+	// Remember the content even if it's invalid, to report the error only
+	// once per change.
+	w.data = data
+
+	f, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("config %s: %v", w.path, err)
+	}
+	return w.apply(f)
+}
@@ -0,0 +1,198 @@
+// Package envoyrls implements the Rate Limit Service (RLS) of Envoy, i.e.
+// envoy.service.ratelimit.v3.RateLimitService, by mapping the descriptors
+// to sliding-window limiters, so that Envoy's global rate limit filter can
+// call into this library:
+//
+//	srv, err := envoyrls.NewServer(rules, func(key string) (sw.Window, sw.StopFunc) {
+//	    return sw.NewSyncWindow(key, sw.NewBlockingSynchronizer(store, 500*time.Millisecond))
+//	})
+//	s := grpc.NewServer()
+//	rlsv3.RegisterRateLimitServiceServer(s, srv)
+package envoyrls
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Entry is a key/value pair of a descriptor.
+type Entry struct {
+	Key string
+	// The value to match. Empty value matches any value of Key, while each
+	// distinct value still has its own limiter (e.g. per remote address).
+	Value string
+}
+
+// Rule applies a limit to the descriptors matching its domain and entries.
+type Rule struct {
+	// The name of the rule, which is reported in the current limit.
+	Name string
+
+	Domain  string
+	Entries []Entry
+
+	// The limit, i.e. the number of requests permitted per Unit. Only
+	// SECOND, MINUTE, HOUR and DAY are supported as the window size.
+	RequestsPerUnit uint32
+	Unit            rlsv3.RateLimitResponse_RateLimit_Unit
+}
+
+// match reports whether the rule matches the descriptor entries within domain.
+func (r *Rule) match(domain string, entries []Entry) bool {
+	if r.Domain != domain || len(r.Entries) != len(entries) {
+		return false
+	}
+	for i, e := range r.Entries {
+		if e.Key != entries[i].Key || (e.Value != "" && e.Value != entries[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// unitSizes are the window sizes of the supported units.
+var unitSizes = map[rlsv3.RateLimitResponse_RateLimit_Unit]time.Duration{
+	rlsv3.RateLimitResponse_RateLimit_SECOND: time.Second,
+	rlsv3.RateLimitResponse_RateLimit_MINUTE: time.Minute,
+	rlsv3.RateLimitResponse_RateLimit_HOUR:   time.Hour,
+	rlsv3.RateLimitResponse_RateLimit_DAY:    24 * time.Hour,
+}
+
+// rule is a Rule along with the limiters of the matching descriptors.
+type rule struct {
+	Rule
+	lim *sw.KeyedLimiter
+}
+
+// Server implements the RateLimitService of Envoy.
+//
+// Every descriptor is checked against the rules in order, and is limited
+// by the first matching rule (so the more specific rules should be listed
+// first). Descriptors matching no rule are always permitted. As in the
+// reference implementation of Envoy, the hits are counted for all the
+// limited descriptors, even if some of them are over limit.
+//
+// Note that the limit overrides carried by descriptors are ignored.
+type Server struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+
+	rules []*rule
+}
+
+// NewServer creates a new server enforcing rules, whose limiters keep the
+// counts in the windows created by newWindow. The keys passed to newWindow
+// identify the descriptors, e.g. "domain|key1=value1|key2=value2".
+func NewServer(rules []Rule, newWindow sw.NewKeyedWindow) (*Server, error) {
+	s := &Server{}
+	for _, r := range rules {
+		size, ok := unitSizes[r.Unit]
+		if !ok {
+			return nil, fmt.Errorf("envoyrls: rule %q has unsupported unit %v", r.Name, r.Unit)
+		}
+		lim := sw.NewKeyedLimiter(size, int64(r.RequestsPerUnit), newWindow)
+		// The descriptor values (e.g. client addresses) may be unbounded.
+		lim.SetEvictInterval(size)
+		s.rules = append(s.rules, &rule{Rule: r, lim: lim})
+	}
+	return s, nil
+}
+
+// Stop stops the possible sync behaviour within all the windows.
+func (s *Server) Stop() {
+	for _, r := range s.rules {
+		r.lim.Stop()
+	}
+}
+
+// ShouldRateLimit checks all the descriptors of req, and reports OVER_LIMIT
+// if any of them is over limit.
+func (s *Server) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	now := time.Now()
+
+	resp := &rlsv3.RateLimitResponse{OverallCode: rlsv3.RateLimitResponse_OK}
+	for _, d := range req.GetDescriptors() {
+		entries := make([]Entry, len(d.GetEntries()))
+		for i, e := range d.GetEntries() {
+			entries[i] = Entry{Key: e.GetKey(), Value: e.GetValue()}
+		}
+
+		hits := uint64(req.GetHitsAddend())
+		if d.GetHitsAddend() != nil {
+			hits = d.GetHitsAddend().GetValue()
+		}
+		if hits == 0 {
+			hits = 1
+		}
+
+		status := s.check(req.GetDomain(), entries, now, hits)
+		if status.Code == rlsv3.RateLimitResponse_OVER_LIMIT {
+			resp.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+		resp.Statuses = append(resp.Statuses, status)
+	}
+	return resp, nil
+}
+
+// check counts hits of the descriptor entries within domain at time now.
+func (s *Server) check(domain string, entries []Entry, now time.Time, hits uint64) *rlsv3.RateLimitResponse_DescriptorStatus {
+	for _, r := range s.rules {
+		if !r.match(domain, entries) {
+			continue
+		}
+
+		lim := r.lim.Limiter(descriptorKey(domain, entries))
+		code := rlsv3.RateLimitResponse_OK
+		// Hits beyond the limit are never allowed, so they are denied right
+		// away, before they could overflow int64.
+		if hits > uint64(lim.Limit()) || !lim.AllowN(now, int64(hits)) {
+			code = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+
+		remaining := lim.Limit() - lim.Count(now)
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		// The count of the current window will take effect fully until
+		// the window ends.
+		size := lim.Size()
+		untilReset := size - now.Sub(now.Truncate(size))
+
+		return &rlsv3.RateLimitResponse_DescriptorStatus{
+			Code: code,
+			CurrentLimit: &rlsv3.RateLimitResponse_RateLimit{
+				Name:            r.Name,
+				RequestsPerUnit: r.RequestsPerUnit,
+				Unit:            r.Unit,
+			},
+			LimitRemaining:     uint32(remaining),
+			DurationUntilReset: durationpb.New(untilReset),
+		}
+	}
+	return &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK}
+}
+
+// keyEscaper escapes the separators of descriptorKey (and the escape
+// character itself), so that the parts never run into each other.
+var keyEscaper = strings.NewReplacer("%", "%25", "|", "%7C", "=", "%3D")
+
+// descriptorKey returns the key identifying the descriptor entries within
+// domain, e.g. "api|user=alice". Distinct descriptors always have distinct
+// keys, since the separators within the parts are escaped.
+func descriptorKey(domain string, entries []Entry) string {
+	var b strings.Builder
+	keyEscaper.WriteString(&b, domain) // nolint:errcheck
+	for _, e := range entries {
+		b.WriteByte('|')
+		keyEscaper.WriteString(&b, e.Key) // nolint:errcheck
+		b.WriteByte('=')
+		keyEscaper.WriteString(&b, e.Value) // nolint:errcheck
+	}
+	return b.String()
+}
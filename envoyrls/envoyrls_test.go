@@ -0,0 +1,197 @@
+package envoyrls
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	commonv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func newLocalWindow(key string) (sw.Window, sw.StopFunc) {
+	return sw.NewLocalWindow()
+}
+
+func descriptor(kvs ...string) *commonv3.RateLimitDescriptor {
+	d := &commonv3.RateLimitDescriptor{}
+	for i := 0; i < len(kvs); i += 2 {
+		d.Entries = append(d.Entries, &commonv3.RateLimitDescriptor_Entry{Key: kvs[i], Value: kvs[i+1]})
+	}
+	return d
+}
+
+func TestServer_ShouldRateLimit(t *testing.T) {
+	s, err := NewServer([]Rule{
+		{
+			Name:            "admin",
+			Domain:          "api",
+			Entries:         []Entry{{Key: "user", Value: "admin"}},
+			RequestsPerUnit: 100,
+			Unit:            rlsv3.RateLimitResponse_RateLimit_MINUTE,
+		},
+		{
+			Name:            "per-user",
+			Domain:          "api",
+			Entries:         []Entry{{Key: "user"}},
+			RequestsPerUnit: 2,
+			Unit:            rlsv3.RateLimitResponse_RateLimit_MINUTE,
+		},
+	}, newLocalWindow)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Stop()
+
+	type status struct {
+		code      rlsv3.RateLimitResponse_Code
+		rule      string
+		remaining uint32
+	}
+	cases := []struct {
+		req     *rlsv3.RateLimitRequest
+		overall rlsv3.RateLimitResponse_Code
+		want    []status
+	}{
+		{
+			req: &rlsv3.RateLimitRequest{
+				Domain:      "api",
+				Descriptors: []*commonv3.RateLimitDescriptor{descriptor("user", "alice")},
+			},
+			overall: rlsv3.RateLimitResponse_OK,
+			want:    []status{{rlsv3.RateLimitResponse_OK, "per-user", 1}},
+		},
+		{
+			req: &rlsv3.RateLimitRequest{
+				Domain: "api",
+				Descriptors: []*commonv3.RateLimitDescriptor{
+					descriptor("user", "alice"),
+					descriptor("user", "bob"),
+					descriptor("path", "/"), // no rule
+				},
+			},
+			overall: rlsv3.RateLimitResponse_OK,
+			want: []status{
+				{rlsv3.RateLimitResponse_OK, "per-user", 0},
+				{rlsv3.RateLimitResponse_OK, "per-user", 1},
+				{rlsv3.RateLimitResponse_OK, "", 0},
+			},
+		},
+		{
+			req: &rlsv3.RateLimitRequest{
+				Domain: "api",
+				Descriptors: []*commonv3.RateLimitDescriptor{
+					descriptor("user", "alice"),
+					descriptor("user", "admin"),
+				},
+				HitsAddend: 10,
+			},
+			overall: rlsv3.RateLimitResponse_OVER_LIMIT,
+			want: []status{
+				{rlsv3.RateLimitResponse_OVER_LIMIT, "per-user", 0},
+				{rlsv3.RateLimitResponse_OK, "admin", 90},
+			},
+		},
+		{
+			req: &rlsv3.RateLimitRequest{
+				Domain: "api",
+				Descriptors: []*commonv3.RateLimitDescriptor{
+					{
+						Entries:    descriptor("user", "admin").Entries,
+						HitsAddend: wrapperspb.UInt64(20),
+					},
+				},
+				HitsAddend: 10,
+			},
+			overall: rlsv3.RateLimitResponse_OK,
+			want:    []status{{rlsv3.RateLimitResponse_OK, "admin", 70}},
+		},
+		{
+			req: &rlsv3.RateLimitRequest{
+				Domain: "api",
+				Descriptors: []*commonv3.RateLimitDescriptor{
+					{
+						Entries:    descriptor("user", "admin").Entries,
+						HitsAddend: wrapperspb.UInt64(math.MaxUint64), // not counted
+					},
+				},
+			},
+			overall: rlsv3.RateLimitResponse_OVER_LIMIT,
+			want:    []status{{rlsv3.RateLimitResponse_OVER_LIMIT, "admin", 70}},
+		},
+		{
+			req: &rlsv3.RateLimitRequest{
+				Domain:      "web", // no rule
+				Descriptors: []*commonv3.RateLimitDescriptor{descriptor("user", "alice")},
+			},
+			overall: rlsv3.RateLimitResponse_OK,
+			want:    []status{{rlsv3.RateLimitResponse_OK, "", 0}},
+		},
+	}
+	for i, c := range cases {
+		resp, err := s.ShouldRateLimit(context.Background(), c.req)
+		if err != nil {
+			t.Fatalf("#%d: err: %v", i, err)
+		}
+		if resp.OverallCode != c.overall {
+			t.Errorf("#%d: OverallCode: got %v, want: %v", i, resp.OverallCode, c.overall)
+		}
+		if len(resp.Statuses) != len(c.want) {
+			t.Fatalf("#%d: Statuses: got %d, want: %d", i, len(resp.Statuses), len(c.want))
+		}
+		for j, w := range c.want {
+			st := resp.Statuses[j]
+			got := status{st.Code, st.GetCurrentLimit().GetName(), st.LimitRemaining}
+			if got != w {
+				t.Errorf("#%d: Statuses[%d]: got %+v, want: %+v", i, j, got, w)
+			}
+			if st.CurrentLimit != nil {
+				if d := st.DurationUntilReset.AsDuration(); d <= 0 || d > 60e9 {
+					t.Errorf("#%d: Statuses[%d].DurationUntilReset: got %v", i, j, d)
+				}
+			}
+		}
+	}
+}
+
+func TestDescriptorKey(t *testing.T) {
+	// Each pair collides without escaping.
+	pairs := [][2][]Entry{
+		{
+			{{Key: "user", Value: "a|b=c"}},
+			{{Key: "user", Value: "a"}, {Key: "b", Value: "c"}},
+		},
+		{
+			{{Key: "a=b", Value: "c"}},
+			{{Key: "a", Value: "b=c"}},
+		},
+		{
+			{{Key: "a", Value: "%7C"}},
+			{{Key: "a", Value: "|"}},
+		},
+	}
+	for _, p := range pairs {
+		k1, k2 := descriptorKey("api", p[0]), descriptorKey("api", p[1])
+		if k1 == k2 {
+			t.Errorf("descriptorKey(%v) = descriptorKey(%v) = %q", p[0], p[1], k1)
+		}
+	}
+
+	if got, want := descriptorKey("api", []Entry{{Key: "user", Value: "alice"}}), "api|user=alice"; got != want {
+		t.Errorf("descriptorKey: got %q, want: %q", got, want)
+	}
+}
+
+func TestNewServer_UnsupportedUnit(t *testing.T) {
+	_, err := NewServer([]Rule{{
+		Name:            "monthly",
+		Domain:          "api",
+		RequestsPerUnit: 1,
+		Unit:            rlsv3.RateLimitResponse_RateLimit_MONTH,
+	}}, newLocalWindow)
+	if err == nil {
+		t.Error("err: got nil, want: unsupported unit")
+	}
+}
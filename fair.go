@@ -0,0 +1,157 @@
+package slidingwindow
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// FairLimiter enforces a global limit shared by many keys (e.g. tenants),
+// while dividing the limit among the currently active keys with max-min
+// fairness, so that no single key can consume the whole window.
+//
+// The demand of a key is estimated by the events of the key, whether they
+// are permitted or not, during the sliding window. A key whose demand is
+// below its fair share leaves the rest of the share to the other keys.
+type FairLimiter struct {
+	global     *Limiter
+	stopGlobal StopFunc
+
+	// The permitted and the denied events of each key.
+	allowed *KeyedLimiter
+	denied  *KeyedLimiter
+
+	level       int64 // The fair share that a single key may consume.
+	refreshedAt int64 // The last time (in nanoseconds) when level was computed.
+	refreshing  int32 // Whether level is being computed.
+}
+
+// NewFairLimiter creates a new fair limiter, whose global limit is enforced
+// with the windows created by newWindow, and whose per-key counts are kept
+// in the windows created by newKeyedWindow.
+//
+// The idle keys are evicted every window size, so that only the active keys
+// are tracked.
+func NewFairLimiter(size time.Duration, limit int64, newWindow NewWindow, newKeyedWindow NewKeyedWindow) *FairLimiter {
+	global, stop := NewLimiter(size, limit, newWindow)
+	f := &FairLimiter{
+		global:     global,
+		stopGlobal: stop,
+		allowed:    NewKeyedLimiter(size, limit, newKeyedWindow),
+		denied: NewKeyedLimiter(size, math.MaxInt64, func(key string) (Window, StopFunc) {
+			return NewLocalWindow()
+		}),
+		level: limit,
+	}
+	f.allowed.SetEvictInterval(size)
+	f.denied.SetEvictInterval(size)
+	return f
+}
+
+// Global returns the limiter that enforces the global limit.
+func (f *FairLimiter) Global() *Limiter {
+	return f.global
+}
+
+// Allow is shorthand for AllowN(key, time.Now(), 1).
+func (f *FairLimiter) Allow(key string) bool {
+	return f.AllowN(key, time.Now(), 1)
+}
+
+// AllowN reports whether n events of key may happen at time now.
+func (f *FairLimiter) AllowN(key string, now time.Time, n int64) bool {
+	lim := f.allowed.Limiter(key)
+
+	if lim.allowN(now, n, f.fairShare(now)) {
+		if f.global.AllowN(now, n) {
+			return true
+		}
+		lim.refundN(now, n)
+	}
+
+	f.denied.AllowN(key, now, n)
+	return false
+}
+
+// Stop stops the possible sync behaviour within all the windows.
+func (f *FairLimiter) Stop() {
+	f.stopGlobal()
+	f.allowed.Stop()
+	f.denied.Stop()
+}
+
+// fairShare returns the fair share of each key at time now. To avoid
+// scanning all the keys for every decision, the share is recomputed at
+// most once per tenth of the window size, by only one caller, while the
+// others keep using the previous share without waiting.
+func (f *FairLimiter) fairShare(now time.Time) int64 {
+	t := now.UnixNano()
+	refreshedAt := atomic.LoadInt64(&f.refreshedAt)
+	elapsed := t - refreshedAt
+	if refreshedAt != 0 && elapsed >= 0 && elapsed < int64(f.global.Size()/10) {
+		return atomic.LoadInt64(&f.level)
+	}
+	if !atomic.CompareAndSwapInt32(&f.refreshing, 0, 1) {
+		return atomic.LoadInt64(&f.level)
+	}
+	defer atomic.StoreInt32(&f.refreshing, 0)
+
+	var demands []int64
+	for _, key := range mergeKeys(f.allowed.Keys(), f.denied.Keys()) {
+		var demand int64
+		if lim, ok := f.allowed.Lookup(key); ok {
+			demand += lim.Count(now)
+		}
+		if lim, ok := f.denied.Lookup(key); ok {
+			demand += lim.Count(now)
+		}
+		if demand > 0 {
+			demands = append(demands, demand)
+		}
+	}
+
+	level := maxMinShare(f.global.Limit(), demands)
+	atomic.StoreInt64(&f.level, level)
+	atomic.StoreInt64(&f.refreshedAt, t)
+	return level
+}
+
+// mergeKeys merges two sorted lists of keys, without duplicates.
+func mergeKeys(a, b []string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0] < b[0]):
+			keys, a = append(keys, a[0]), a[1:]
+		case len(a) == 0 || b[0] < a[0]:
+			keys, b = append(keys, b[0]), b[1:]
+		default:
+			keys, a, b = append(keys, a[0]), a[1:], b[1:]
+		}
+	}
+	return keys
+}
+
+// maxMinShare returns the level that capacity is divided at among the
+// given demands with max-min fairness (i.e. water-filling), where demands
+// below the level are fully satisfied, and the others are capped by it.
+func maxMinShare(capacity int64, demands []int64) int64 {
+	if len(demands) == 0 {
+		return capacity
+	}
+
+	sort.Slice(demands, func(i, j int) bool { return demands[i] < demands[j] })
+
+	remaining := capacity
+	for i, demand := range demands {
+		share := remaining / int64(len(demands)-i)
+		if demand >= share {
+			return share
+		}
+		remaining -= demand
+	}
+
+	// All the demands are satisfied, so any key may take the rest.
+	return demands[len(demands)-1] + remaining
+}
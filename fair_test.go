@@ -0,0 +1,85 @@
+package slidingwindow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFairLimiter_AllowN(t *testing.T) {
+	f := NewFairLimiter(size, limit,
+		func() (Window, StopFunc) {
+			return NewLocalWindow()
+		},
+		func(key string) (Window, StopFunc) {
+			return NewLocalWindow()
+		},
+	)
+	defer f.Stop()
+
+	cases := []struct {
+		key string
+		caseArg
+	}{
+		// demands: none, share: 10
+		{"a", caseArg{t0, 6, true}},
+
+		// demands: a=6, share: 10
+		{"b", caseArg{t1, 2, true}},
+
+		// demands: b=2, a=6, share: 8
+		{"a", caseArg{t2, 3, false}}, // a will exceed its share
+		{"a", caseArg{t2, 2, true}},
+
+		// demands: b=2, a=8+3, share: 8
+		{"b", caseArg{t3, 1, false}}, // the global limit is reached
+
+		// demands: b=2+1, a=8+3, share: 7
+		{"a", caseArg{t5, 1, false}}, // a is capped by its share now
+		{"b", caseArg{t5, 2, false}},
+
+		// prev-window: [t0, t0 + 1s), global count: 10
+		// demands: b=2/2+3/2, a=8/2+4/2, share: 8
+		{"b", caseArg{t15, 3, true}},
+		{"a", caseArg{t15, 3, false}},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := f.AllowN(c.key, c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("f.AllowN(%q, %v, %v) = %v, want: %v",
+					c.key, c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+}
+
+func TestMaxMinShare(t *testing.T) {
+	cases := []struct {
+		capacity int64
+		demands  []int64
+		want     int64
+	}{
+		{10, nil, 10},
+		{10, []int64{3}, 10},
+		{10, []int64{5, 5}, 5},
+		{10, []int64{2, 20}, 8},
+		{12, []int64{2, 20, 20}, 5},
+		{12, []int64{1, 2, 3}, 9},
+	}
+
+	for _, c := range cases {
+		demands := append([]int64(nil), c.demands...)
+		if got := maxMinShare(c.capacity, demands); got != c.want {
+			t.Errorf("maxMinShare(%d, %v) = %d, want: %d", c.capacity, c.demands, got, c.want)
+		}
+	}
+}
+
+func TestMergeKeys(t *testing.T) {
+	got := mergeKeys([]string{"a", "c", "d"}, []string{"b", "c", "e"})
+	want := []string{"a", "b", "c", "d", "e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeKeys() = %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+// Package filestore implements the Datastore on a local JSON file, which is
+// handy for development and tests that should survive restarts, without
+// running a Redis server.
+//
+// The file holds a JSON object mapping "key@start" to the count, i.e. the
+// same layout as redisstore, where start is the start boundary of the
+// window in Unix nanoseconds:
+//
+//	{"user:1@1600000000000000000": 3}
+//
+// The file is rewritten atomically on every change, so the store is only
+// suitable for low traffic. It must not be shared by multiple processes,
+// since the changes are only serialized within the process.
+package filestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// FileDatastore is the file-based datastore.
+type FileDatastore struct {
+	path string
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// Open opens the datastore kept in the file at path, which will be created
+// on the first change if it does not exist.
+func Open(path string) (*FileDatastore, error) {
+	d := &FileDatastore{path: path, counts: make(map[string]int64)}
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return d, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.counts); err != nil {
+		return nil, fmt.Errorf("filestore: %s: %v", path, err)
+	}
+	return d, nil
+}
+
+func (d *FileDatastore) fullKey(key string, start int64) string {
+	return fmt.Sprintf("%s@%d", key, start)
+}
+
+func (d *FileDatastore) Add(key string, start, value int64) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	k := d.fullKey(key, start)
+	old, existed := d.counts[k]
+	d.counts[k] = old + value
+	if err := d.save(); err != nil {
+		// Roll back, to stay consistent with the file.
+		if existed {
+			d.counts[k] = old
+		} else {
+			delete(d.counts, k)
+		}
+		return 0, err
+	}
+	return old + value, nil
+}
+
+func (d *FileDatastore) Get(key string, start int64) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.counts[d.fullKey(key, start)], nil
+}
+
+// List returns the windows whose keys have the given prefix, sorted by key
+// and then by start. The entries not in the "key@start" layout are skipped.
+func (d *FileDatastore) List(prefix string) ([]sw.WindowCount, error) {
+	d.mu.Lock()
+	var counts []sw.WindowCount
+	for full, count := range d.counts {
+		i := strings.LastIndexByte(full, '@')
+		if i < 0 || !strings.HasPrefix(full[:i], prefix) {
+			continue
+		}
+		start, err := strconv.ParseInt(full[i+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		counts = append(counts, sw.WindowCount{Key: full[:i], Start: start, Count: count})
+	}
+	d.mu.Unlock()
+
+	sw.SortWindowCounts(counts)
+	return counts, nil
+}
+
+// Delete deletes the window represented by start.
+func (d *FileDatastore) Delete(key string, start int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	k := d.fullKey(key, start)
+	old, existed := d.counts[k]
+	if !existed {
+		return nil
+	}
+	delete(d.counts, k)
+	if err := d.save(); err != nil {
+		d.counts[k] = old
+		return err
+	}
+	return nil
+}
+
+// save writes the counts to a temporary file, and then renames it to the
+// path, so that the file is never left half-written.
+func (d *FileDatastore) save() error {
+	data, err := json.MarshalIndent(d.counts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(d.path), filepath.Base(d.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), d.path)
+}
@@ -0,0 +1,65 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+func TestFileDatastore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "counts.json")
+
+	d, err := Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	adds := []sw.WindowCount{
+		{Key: "b", Start: 1, Count: 2},
+		{Key: "a@x", Start: 2, Count: 3},
+		{Key: "a@x", Start: 1, Count: 4},
+		{Key: "c", Start: 1, Count: 5},
+		{Key: "a@x", Start: 1, Count: 1},
+	}
+	for _, w := range adds {
+		if _, err := d.Add(w.Key, w.Start, w.Count); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := d.Delete("c", 1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Reopen to check that the changes have been persisted.
+	d, err = Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got, _ := d.Get("a@x", 1); got != 5 {
+		t.Errorf("Get: got %d, want: 5", got)
+	}
+
+	got, err := d.List("a")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := []sw.WindowCount{{Key: "a@x", Start: 1, Count: 5}, {Key: "a@x", Start: 2, Count: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %v, want: %v", got, want)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Errorf("Open: got nil err for a corrupted file")
+	}
+}
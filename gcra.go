@@ -0,0 +1,297 @@
+package slidingwindow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// maxCASAttempts is the maximum number of compare-and-set attempts within
+// one decision, before the decision is given up due to contention.
+const maxCASAttempts = 10
+
+// CASDatastore represents a central datastore that additionally supports
+// compare-and-set, which is required by SyncTAT.
+type CASDatastore interface {
+	Datastore
+
+	// CompareAndSet sets the count of the window represented by start to new,
+	// only if the current count is old. It reports whether the count is set.
+	CompareAndSet(key string, start, old, new int64) (bool, error)
+}
+
+// TAT represents the storage of the theoretical arrival time (TAT), which
+// is the only state of a GCRA limiter.
+type TAT interface {
+	// Load returns the theoretical arrival time (timestamp in nanoseconds).
+	Load() (int64, error)
+
+	// CompareAndSwap sets the theoretical arrival time to new, only if the
+	// current one is old. It reports whether the swap happened.
+	CompareAndSwap(old, new int64) (bool, error)
+}
+
+// LocalTAT represents a TAT that only stores the arrival time in memory.
+type LocalTAT struct {
+	tat int64
+}
+
+func NewLocalTAT() *LocalTAT {
+	return &LocalTAT{}
+}
+
+func (t *LocalTAT) Load() (int64, error) {
+	return t.tat, nil
+}
+
+func (t *LocalTAT) CompareAndSwap(old, new int64) (bool, error) {
+	if t.tat != old {
+		return false, nil
+	}
+	t.tat = new
+	return true, nil
+}
+
+// SyncTAT represents a TAT that is shared by all the limiters through
+// the central datastore.
+//
+// Unlike SyncWindow, every decision made with SyncTAT involves a round trip
+// to the datastore, since GCRA can not tolerate the inaccuracy of a local copy.
+type SyncTAT struct {
+	key   string
+	store CASDatastore
+}
+
+// NewSyncTAT creates an instance of SyncTAT with the given datastore.
+//
+// The arrival time is stored as the count of the window whose start is 0,
+// so that it shares the same key layout with the sliding windows.
+func NewSyncTAT(key string, store CASDatastore) *SyncTAT {
+	return &SyncTAT{key: key, store: store}
+}
+
+func (t *SyncTAT) Load() (int64, error) {
+	return t.store.Get(t.key, 0)
+}
+
+func (t *SyncTAT) CompareAndSwap(old, new int64) (bool, error) {
+	return t.store.CompareAndSet(t.key, 0, old, new)
+}
+
+// GCRALimiter implements the Generic Cell Rate Algorithm, which is
+// equivalent to a token bucket that permits limit events during one window
+// size, with bursts of at most burst events.
+//
+// Compared with Limiter, GCRALimiter spreads the events evenly over the
+// window size, instead of permitting all of them at the start of a window.
+type GCRALimiter struct {
+	size  time.Duration
+	limit int64
+	burst int64
+
+	mu  sync.Mutex
+	tat TAT
+}
+
+// NewGCRALimiter creates a new GCRA limiter, which stores its state in tat.
+// A limiter whose limit is not positive denies all events.
+func NewGCRALimiter(size time.Duration, limit, burst int64, tat TAT) *GCRALimiter {
+	return &GCRALimiter{
+		size:  size,
+		limit: limit,
+		burst: burst,
+		tat:   tat,
+	}
+}
+
+// Size returns the time duration of one window size.
+func (lim *GCRALimiter) Size() time.Duration {
+	return lim.size
+}
+
+// Limit returns the maximum events permitted to happen during one window size.
+func (lim *GCRALimiter) Limit() int64 {
+	return lim.limit
+}
+
+// Burst returns the maximum events permitted to happen at once.
+func (lim *GCRALimiter) Burst() int64 {
+	return lim.burst
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1).
+func (lim *GCRALimiter) Allow() bool {
+	return lim.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at time now.
+func (lim *GCRALimiter) AllowN(now time.Time, n int64) bool {
+	_, ok := lim.reserveN(now, n, 0)
+	return ok
+}
+
+// Reserve is shorthand for ReserveN(time.Now(), 1).
+func (lim *GCRALimiter) Reserve() (time.Duration, bool) {
+	return lim.ReserveN(time.Now(), 1)
+}
+
+// ReserveN reserves n events at time now, and returns how long to wait
+// before they may happen. It reports false, without reserving anything,
+// if n exceeds the burst, since such events will never be allowed.
+//
+// Unlike AllowN, the reserved events are always counted, so the caller
+// is expected to wait for the returned duration before acting.
+func (lim *GCRALimiter) ReserveN(now time.Time, n int64) (time.Duration, bool) {
+	return lim.reserveN(now, n, time.Duration(math.MaxInt64))
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (lim *GCRALimiter) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events may happen, or ctx is done. It returns
+// an error if n exceeds the burst, or if ctx would be done before the
+// events may happen, in which case nothing is reserved.
+//
+// Unlike Limiter.WaitN, which polls, the exact time to wait is known in
+// advance, since the arrival time is the only state of GCRA.
+func (lim *GCRALimiter) WaitN(ctx context.Context, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	maxDelay := time.Duration(math.MaxInt64)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxDelay = deadline.Sub(now)
+	}
+
+	delay, ok := lim.reserveN(now, n, maxDelay)
+	if !ok {
+		if n > lim.burst {
+			return fmt.Errorf("slidingwindow: n %d exceeds burst %d", n, lim.burst)
+		}
+		return fmt.Errorf("slidingwindow: waiting for %d events would exceed the deadline", n)
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the events that will not happen.
+		lim.cancelN(n)
+		return ctx.Err()
+	}
+}
+
+// reserveN reserves n events at time now, provided that they may happen
+// within maxDelay, and returns how long to wait before they may happen.
+// A zero maxDelay means the events must be allowed right away.
+func (lim *GCRALimiter) reserveN(now time.Time, n int64, maxDelay time.Duration) (time.Duration, bool) {
+	if lim.limit <= 0 || n > lim.burst {
+		return 0, false
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	// The maximum time that the arrival time may be ahead of now.
+	tolerance := lim.cost(lim.burst)
+
+	t := now.UnixNano()
+	for i := 0; i < maxCASAttempts; i++ {
+		tat, err := lim.tat.Load()
+		if err != nil {
+			// Fail open, just like SyncWindow which keeps working with its
+			// local count when the datastore is unavailable.
+			log.Printf("err: %v\n", err)
+			return 0, true
+		}
+
+		newTAT := tat
+		if newTAT < t {
+			newTAT = t
+		}
+		newTAT += lim.cost(n)
+
+		var delay time.Duration
+		if ahead := newTAT - t; ahead > tolerance {
+			delay = time.Duration(ahead - tolerance)
+		}
+		if delay > maxDelay {
+			return 0, false
+		}
+
+		ok, err := lim.tat.CompareAndSwap(tat, newTAT)
+		if err != nil {
+			log.Printf("err: %v\n", err)
+			return 0, true
+		}
+		if ok {
+			return delay, true
+		}
+		// The arrival time has been changed by another limiter, try again.
+	}
+
+	return 0, false
+}
+
+// cost returns the time cost by n events, i.e. n emission intervals.
+//
+// It is computed as a whole, and rounded up, instead of multiplying n by
+// the emission interval size/limit, which truncates to 0 if limit exceeds
+// the window size in nanoseconds, and would then let all events happen.
+func (lim *GCRALimiter) cost(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	hi, lo := bits.Mul64(uint64(n), uint64(lim.size))
+	if hi >= uint64(lim.limit) {
+		// The quotient would overflow.
+		return math.MaxInt64
+	}
+	q, r := bits.Div64(hi, lo, uint64(lim.limit))
+	if r > 0 {
+		q++
+	}
+	if q > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(q)
+}
+
+// cancelN gives back n reserved events, by moving the arrival time back.
+func (lim *GCRALimiter) cancelN(n int64) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	back := lim.cost(n)
+	for i := 0; i < maxCASAttempts; i++ {
+		tat, err := lim.tat.Load()
+		if err != nil {
+			log.Printf("err: %v\n", err)
+			return
+		}
+		ok, err := lim.tat.CompareAndSwap(tat, tat-back)
+		if err != nil {
+			log.Printf("err: %v\n", err)
+			return
+		}
+		if ok {
+			return
+		}
+	}
+}
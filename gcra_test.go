@@ -0,0 +1,148 @@
+package slidingwindow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGCRALimiter_LocalTAT_AllowN(t *testing.T) {
+	// The emission interval is 100ms, and at most 3 events may happen at once.
+	lim := NewGCRALimiter(size, limit, 3, NewLocalTAT())
+
+	cases := []caseArg{
+		// TAT: t0
+		{t0, 3, true},
+		{t0, 1, false}, // TAT will be t0 + 400ms, which is too far ahead of t0
+
+		// TAT: t3
+		{t1, 1, true},
+		{t1, 1, false},
+
+		// TAT: t4
+		{t5, 1, true},
+		{t5, 2, true},
+		{t5, 1, false},
+
+		// TAT: t8
+		{t10, 3, true},
+		{t10, 1, false},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := lim.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("lim.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+}
+
+func TestGCRALimiter_SyncTAT_AllowN(t *testing.T) {
+	store := newMemDatastore()
+	lim1 := NewGCRALimiter(size, limit, 3, NewSyncTAT("test", store))
+	lim2 := NewGCRALimiter(size, limit, 3, NewSyncTAT("test", store))
+
+	cases := []struct {
+		lim *GCRALimiter
+		caseArg
+	}{
+		{lim1, caseArg{t0, 2, true}},
+		{lim2, caseArg{t0, 2, false}}, // the TAT has been moved ahead by lim1
+		{lim2, caseArg{t0, 1, true}},
+		{lim1, caseArg{t0, 1, false}},
+		{lim1, caseArg{t2, 2, true}},
+		{lim2, caseArg{t2, 1, false}},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := c.lim.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("lim.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+}
+
+func TestGCRALimiter_ReserveN(t *testing.T) {
+	lim := NewGCRALimiter(size, limit, 3, NewLocalTAT())
+
+	cases := []struct {
+		t     time.Time
+		n     int64
+		delay time.Duration
+		ok    bool
+	}{
+		{t0, 3, 0, true},
+		{t0, 2, 2 * d, true}, // TAT: t5, which is 2 intervals beyond the tolerance
+		{t1, 1, 2 * d, true},
+		{t1, 4, 0, false}, // exceeds the burst
+	}
+
+	for _, c := range cases {
+		delay, ok := lim.ReserveN(c.t, c.n)
+		if delay != c.delay || ok != c.ok {
+			t.Errorf("lim.ReserveN(%v, %v) = (%v, %v), want: (%v, %v)",
+				c.t, c.n, delay, ok, c.delay, c.ok)
+		}
+	}
+}
+
+func TestGCRALimiter_WaitN(t *testing.T) {
+	lim := NewGCRALimiter(size, limit, 1, NewLocalTAT())
+
+	if err := lim.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := lim.WaitN(context.Background(), 2); err == nil {
+		t.Errorf("n > burst: got nil err")
+	}
+
+	// The next event may happen after about 100ms, which is beyond the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := lim.WaitN(ctx, 1); err == nil {
+		t.Errorf("exceeding deadline: got nil err")
+	}
+
+	start := time.Now()
+	if err := lim.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("elapsed: got %v, want: about 100ms", elapsed)
+	}
+}
+
+func TestGCRALimiter_ZeroLimit(t *testing.T) {
+	lim := NewGCRALimiter(size, 0, 3, NewLocalTAT())
+	if lim.AllowN(t0, 1) {
+		t.Errorf("lim.AllowN(t0, 1) = true, want: false")
+	}
+}
+
+func TestGCRALimiter_SubNanosecondInterval(t *testing.T) {
+	// The emission interval is 0.5ns, which must not be truncated to 0.
+	lim := NewGCRALimiter(time.Second, 2e9, 4, NewLocalTAT())
+
+	cases := []caseArg{
+		// TAT: t0 + 2ns
+		{t0, 4, true},
+		{t0, 1, false},
+
+		// TAT: t0 + 3ns
+		{t0.Add(time.Nanosecond), 2, true},
+		{t0.Add(time.Nanosecond), 1, false},
+	}
+
+	for _, c := range cases {
+		ok := lim.AllowN(c.t, c.n)
+		if ok != c.ok {
+			t.Errorf("AllowN(%v, %d) = %v, want: %v", c.t, c.n, ok, c.ok)
+		}
+	}
+}
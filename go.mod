@@ -1,3 +1,5 @@
 module github.com/RussellLuo/slidingwindow
 
 go 1.13
+
+require github.com/go-redis/redis v6.15.9+incompatible
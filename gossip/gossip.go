@@ -0,0 +1,357 @@
+// Package gossip implements a peer-to-peer replacement for the central
+// datastore, where every node keeps the counts of all the windows as
+// CRDT counters, and exchanges them with its peers periodically.
+//
+// Each exchange only carries the slots changed since the previous exchange
+// with the same peer, so the traffic of a round is proportional to the
+// changes rather than to the number of windows. The full state is sent to
+// new peers, and again to the peers that have restarted.
+package gossip
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// window identifies the window represented by start, of the given key.
+type window struct {
+	Key   string `json:"key"`
+	Start int64  `json:"start"`
+}
+
+// slot holds the changes made by one node within one window. Increments
+// and decrements (e.g. refunds) are kept separately, so that both of them
+// only grow and can be merged by taking the maximum (i.e. a PN-counter).
+type slot struct {
+	Inc int64 `json:"inc"`
+	Dec int64 `json:"dec"`
+
+	version int64 // The local version when the slot was last changed.
+}
+
+// entry is the serialization format of the counter of one window.
+type entry struct {
+	Window window          `json:"window"`
+	Slots  map[string]slot `json:"slots"`
+}
+
+// cursor identifies the state of a node as of some version. The incarnation
+// changes whenever the node restarts (i.e. loses its state), after which
+// its versions start over.
+type cursor struct {
+	Incarnation int64 `json:"incarnation"`
+	Version     int64 `json:"version"`
+}
+
+// request is the serialization format of the request of an exchange, which
+// carries the changes of the sender, and the state of the receiver that
+// the sender has seen.
+type request struct {
+	Seen    cursor  `json:"seen"`
+	Entries []entry `json:"entries"`
+}
+
+// response is the serialization format of the response of an exchange,
+// which carries the changes of the receiver since the seen state.
+type response struct {
+	At      cursor  `json:"at"`
+	Entries []entry `json:"entries"`
+}
+
+// peer is the progress of the exchanges with a peer.
+type peer struct {
+	sent int64  // The local version whose changes have been sent to the peer.
+	seen cursor // The state of the peer that has been received.
+}
+
+// Node is a member of the gossip cluster. It implements the Datastore
+// interface of slidingwindow, so it can be used just like a central datastore:
+//
+//	node := gossip.NewNode("node-1", peers, 100*time.Millisecond, 2*size)
+//	http.Handle("/gossip", node)
+//	node.Start()
+//	defer node.Stop()
+//
+//	lim, stop := sw.NewLimiter(size, limit, func() (sw.Window, sw.StopFunc) {
+//	    return sw.NewSyncWindow("test", node.Synchronizer())
+//	})
+//
+// Since Add and Get only touch the local state, it's cheap to sync on
+// every call, while the local changes reach other nodes at the next round
+// of gossip.
+type Node struct {
+	id       string
+	interval time.Duration
+	ttl      time.Duration
+	client   *http.Client
+
+	incarnation int64
+
+	mu       sync.Mutex
+	peers    []string
+	progress map[string]*peer // Keyed by the URLs of the peers.
+	version  int64
+	counters map[window]map[string]slot
+
+	stopC chan struct{}
+	exitC chan struct{}
+}
+
+// NewNode creates a new node identified by id, which gossips with the
+// peers (URLs of their handlers) every interval. Windows started more than
+// ttl ago will be discarded.
+func NewNode(id string, peers []string, interval, ttl time.Duration) *Node {
+	return &Node{
+		id:       id,
+		interval: interval,
+		ttl:      ttl,
+		client:   &http.Client{Timeout: interval},
+
+		incarnation: time.Now().UnixNano(),
+
+		peers:    peers,
+		progress: make(map[string]*peer),
+		counters: make(map[window]map[string]slot),
+		stopC:    make(chan struct{}),
+		exitC:    make(chan struct{}),
+	}
+}
+
+// Synchronizer returns a synchronizer that syncs a window with the node on
+// every call, which is cheap since the node keeps its state in memory.
+func (n *Node) Synchronizer() sw.Synchronizer {
+	return sw.NewBlockingSynchronizer(n, 0)
+}
+
+// SetPeers replaces the peers of the node.
+func (n *Node) SetPeers(peers []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.peers = peers
+}
+
+// Add adds delta to the count of the window represented by start, and
+// returns the new count merged from all the known nodes.
+func (n *Node) Add(key string, start, delta int64) (int64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	w := window{Key: key, Start: start}
+	slots, ok := n.counters[w]
+	if !ok {
+		slots = make(map[string]slot)
+		n.counters[w] = slots
+	}
+
+	s := slots[n.id]
+	if delta > 0 {
+		s.Inc += delta
+	} else {
+		s.Dec -= delta
+	}
+	n.version++
+	s.version = n.version
+	slots[n.id] = s
+
+	return sum(slots), nil
+}
+
+// Get returns the count of the window represented by start, which is
+// merged from all the known nodes.
+func (n *Node) Get(key string, start int64) (int64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return sum(n.counters[window{Key: key, Start: start}]), nil
+}
+
+func sum(slots map[string]slot) int64 {
+	var count int64
+	for _, s := range slots {
+		count += s.Inc - s.Dec
+	}
+	return count
+}
+
+// ServeHTTP receives the changes from a peer, merges them into the local
+// state, and responds with the local changes that the peer has not seen.
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n.merge(req.Entries)
+
+	var since int64
+	if req.Seen.Incarnation == n.incarnation {
+		since = req.Seen.Version
+	}
+	n.mu.Lock()
+	resp := response{
+		At:      cursor{Incarnation: n.incarnation, Version: n.version},
+		Entries: n.changes(since),
+	}
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp) // nolint:errcheck
+}
+
+// Start starts the gossip goroutine.
+func (n *Node) Start() {
+	go n.gossipLoop()
+}
+
+// Stop stops the gossip goroutine, and waits for it to exit.
+func (n *Node) Stop() {
+	close(n.stopC)
+	<-n.exitC
+}
+
+func (n *Node) gossipLoop() {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			n.expire(now)
+			n.gossip()
+		case <-n.stopC:
+			close(n.exitC)
+			return
+		}
+	}
+}
+
+// gossip exchanges the state with all the peers.
+func (n *Node) gossip() {
+	n.mu.Lock()
+	peers := n.peers
+	n.mu.Unlock()
+
+	for _, peer := range peers {
+		if err := n.exchange(peer); err != nil {
+			log.Printf("err: %v\n", err)
+		}
+	}
+}
+
+// exchange sends the local changes that url has not seen to it, and merges
+// the changes responded.
+func (n *Node) exchange(url string) error {
+	n.mu.Lock()
+	p, ok := n.progress[url]
+	if !ok {
+		p = &peer{}
+		n.progress[url] = p
+	}
+	version := n.version
+	req := request{Seen: p.seen, Entries: n.changes(p.sent)}
+	n.mu.Unlock()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	var resp response
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return err
+	}
+	n.merge(resp.Entries)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if p.seen.Incarnation != 0 && p.seen.Incarnation != resp.At.Incarnation {
+		// The peer has restarted and lost our changes, so send all of them
+		// next time.
+		p.sent = 0
+	} else {
+		p.sent = version
+	}
+	p.seen = resp.At
+	return nil
+}
+
+// changes returns a copy of the slots changed after the local version since.
+// It must be called with mu held.
+func (n *Node) changes(since int64) []entry {
+	var entries []entry
+	for w, slots := range n.counters {
+		var e entry
+		for id, s := range slots {
+			if s.version <= since {
+				continue
+			}
+			if e.Slots == nil {
+				e = entry{Window: w, Slots: make(map[string]slot)}
+			}
+			e.Slots[id] = s
+		}
+		if e.Slots != nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// merge merges entries into the local state, by taking the maximum of
+// each slot.
+func (n *Node) merge(entries []entry) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, e := range entries {
+		slots, ok := n.counters[e.Window]
+		if !ok {
+			slots = make(map[string]slot)
+			n.counters[e.Window] = slots
+		}
+		for id, s := range e.Slots {
+			local := slots[id]
+			if s.Inc <= local.Inc && s.Dec <= local.Dec {
+				continue
+			}
+			if s.Inc > local.Inc {
+				local.Inc = s.Inc
+			}
+			if s.Dec > local.Dec {
+				local.Dec = s.Dec
+			}
+			n.version++
+			local.version = n.version
+			slots[id] = local
+		}
+	}
+}
+
+// expire discards the windows started more than ttl before now.
+func (n *Node) expire(now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	deadline := now.Add(-n.ttl).UnixNano()
+	for w := range n.counters {
+		if w.Start < deadline {
+			delete(n.counters, w)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package gossip
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+func TestNode(t *testing.T) {
+	size := time.Second
+	now := time.Now().Truncate(size)
+
+	var (
+		nodes    []*Node
+		servers  []*httptest.Server
+		limiters []*sw.Limiter
+	)
+	for _, id := range []string{"node-1", "node-2", "node-3"} {
+		node := NewNode(id, nil, time.Second, 2*size)
+		server := httptest.NewServer(node)
+		defer server.Close()
+
+		lim, stop := sw.NewLimiter(size, 10, func() (sw.Window, sw.StopFunc) {
+			return sw.NewSyncWindow("test", node.Synchronizer())
+		})
+		defer stop()
+
+		nodes = append(nodes, node)
+		servers = append(servers, server)
+		limiters = append(limiters, lim)
+	}
+
+	// Connect the nodes in a line: node-1 <-> node-2 <-> node-3.
+	nodes[0].SetPeers([]string{servers[1].URL})
+	nodes[1].SetPeers([]string{servers[0].URL, servers[2].URL})
+	nodes[2].SetPeers([]string{servers[1].URL})
+
+	cases := []struct {
+		lim int
+		n   int64
+		ok  bool
+	}{
+		{0, 4, true},
+		{2, 4, true}, // node-3 has not heard from node-1 yet
+	}
+	for _, c := range cases {
+		if ok := limiters[c.lim].AllowN(now, c.n); ok != c.ok {
+			t.Errorf("limiters[%d].AllowN(%v) = %v, want: %v", c.lim, c.n, ok, c.ok)
+		}
+	}
+
+	// Two rounds are enough for the changes to spread over the line.
+	gossip := func() {
+		for i := 0; i < 2; i++ {
+			for _, node := range nodes {
+				node.gossip()
+			}
+		}
+	}
+	gossip()
+
+	for i, node := range nodes {
+		if count, _ := node.Get("test", now.UnixNano()); count != 8 {
+			t.Errorf("nodes[%d].Get() = %d, want: 8", i, count)
+		}
+	}
+
+	// Now every limiter sees the count from the others, after its next sync.
+	cases = []struct {
+		lim int
+		n   int64
+		ok  bool
+	}{
+		{1, 0, true}, // trigger the sync
+		{1, 3, false},
+		{1, 2, true},
+	}
+	for _, c := range cases {
+		if ok := limiters[c.lim].AllowN(now, c.n); ok != c.ok {
+			t.Errorf("limiters[%d].AllowN(%v) = %v, want: %v", c.lim, c.n, ok, c.ok)
+		}
+	}
+
+	gossip()
+
+	cases = []struct {
+		lim int
+		n   int64
+		ok  bool
+	}{
+		{0, 0, true}, // trigger the sync
+		{0, 1, false},
+		{2, 0, true}, // trigger the sync
+		{2, 1, false},
+	}
+	for _, c := range cases {
+		if ok := limiters[c.lim].AllowN(now, c.n); ok != c.ok {
+			t.Errorf("limiters[%d].AllowN(%v) = %v, want: %v", c.lim, c.n, ok, c.ok)
+		}
+	}
+
+	// Clearing the counts on one node spreads over the others as well.
+	if err := limiters[0].ClearStore(now); err != nil {
+		t.Fatalf("limiters[0].ClearStore() err = %v", err)
+	}
+	gossip()
+
+	for i, node := range nodes {
+		if count, _ := node.Get("test", now.UnixNano()); count != 0 {
+			t.Errorf("nodes[%d].Get() = %d, want: 0", i, count)
+		}
+	}
+}
+
+func allChanges(n *Node) []entry {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.changes(0)
+}
+
+func TestNode_Exchange(t *testing.T) {
+	n1 := NewNode("node-1", nil, time.Second, time.Minute)
+	n2 := NewNode("node-2", nil, time.Second, time.Minute)
+	server := httptest.NewServer(n2)
+	defer server.Close()
+
+	// pending returns the number of the slots to be sent to node-2.
+	pending := func() int {
+		n1.mu.Lock()
+		defer n1.mu.Unlock()
+
+		var sent int64
+		if p, ok := n1.progress[server.URL]; ok {
+			sent = p.sent
+		}
+		count := 0
+		for _, e := range n1.changes(sent) {
+			count += len(e.Slots)
+		}
+		return count
+	}
+
+	n1.Add("a", 0, 1)
+	n1.Add("b", 0, 1)
+	n2.Add("a", 0, 2)
+	if got := pending(); got != 2 {
+		t.Errorf("pending() = %d, want: 2", got)
+	}
+
+	if err := n1.exchange(server.URL); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if count, _ := n1.Get("a", 0); count != 3 {
+		t.Errorf("node-1.Get(a) = %d, want: 3", count)
+	}
+
+	// Only the slot of node-2 merged in the last exchange is sent back.
+	if got := pending(); got != 1 {
+		t.Errorf("pending() = %d, want: 1", got)
+	}
+	if err := n1.exchange(server.URL); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got := pending(); got != 0 {
+		t.Errorf("pending() = %d, want: 0", got)
+	}
+
+	n1.Add("b", 0, 1)
+	if got := pending(); got != 1 {
+		t.Errorf("pending() = %d, want: 1", got)
+	}
+}
+
+func TestNode_Merge(t *testing.T) {
+	n1 := NewNode("node-1", nil, time.Second, time.Minute)
+	n2 := NewNode("node-2", nil, time.Second, time.Minute)
+
+	n1.Add("test", 0, 5)
+	n1.Add("test", 0, -2) // e.g. a refund
+	n2.Add("test", 0, 1)
+
+	// Merging is idempotent and commutative.
+	n1.merge(allChanges(n2))
+	n1.merge(allChanges(n2))
+	n2.merge(allChanges(n1))
+
+	for i, node := range []*Node{n1, n2} {
+		if count, _ := node.Get("test", 0); count != 4 {
+			t.Errorf("node-%d.Get() = %d, want: 4", i+1, count)
+		}
+	}
+
+	n1.expire(time.Unix(0, 0).Add(2 * time.Minute))
+	if count, _ := n1.Get("test", 0); count != 0 {
+		t.Errorf("node-1.Get() = %d, want: 0 after expiry", count)
+	}
+}
@@ -0,0 +1,187 @@
+// Package grpcstore exposes a Datastore as a gRPC service (see
+// pb/datastore.proto), so that SyncWindow instances can sync through a
+// central counting service.
+//
+// The server side wraps any Datastore:
+//
+//	s := grpc.NewServer(grpc.UnaryInterceptor(grpcstore.TokenAuth(token)))
+//	pb.RegisterDatastoreServer(s, grpcstore.NewServer(store))
+//
+// The client side implements the Datastore interface:
+//
+//	conn, err := grpc.NewClient(addr, grpc.WithUnaryInterceptor(grpcstore.WithToken(token)), ...)
+//	store := grpcstore.NewClient(conn, 100*time.Millisecond)
+//	lim, stop := sw.NewLimiter(size, limit, func() (sw.Window, sw.StopFunc) {
+//	    return sw.NewSyncWindow("test", sw.NewNonblockingSynchronizer(store, 500*time.Millisecond))
+//	})
+package grpcstore
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/grpcstore/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the Datastore service by wrapping a Datastore.
+type Server struct {
+	pb.UnimplementedDatastoreServer
+
+	store sw.Datastore
+}
+
+// NewServer creates a new server that serves the operations of store.
+func NewServer(store sw.Datastore) *Server {
+	return &Server{store: store}
+}
+
+func (s *Server) Add(ctx context.Context, req *pb.AddRequest) (*pb.CountResponse, error) {
+	count, err := s.store.Add(req.GetKey(), req.GetStart(), req.GetDelta())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.CountResponse{Count: count}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.CountResponse, error) {
+	count, err := s.store.Get(req.GetKey(), req.GetStart())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.CountResponse{Count: count}, nil
+}
+
+// BatchAdd performs the Adds in order, and fails on the first error. Note
+// that the Adds before the failed one have been applied.
+func (s *Server) BatchAdd(ctx context.Context, req *pb.BatchAddRequest) (*pb.BatchCountResponse, error) {
+	counts := make([]int64, len(req.GetRequests()))
+	for i, r := range req.GetRequests() {
+		count, err := s.store.Add(r.GetKey(), r.GetStart(), r.GetDelta())
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		counts[i] = count
+	}
+	return &pb.BatchCountResponse{Counts: counts}, nil
+}
+
+func (s *Server) BatchGet(ctx context.Context, req *pb.BatchGetRequest) (*pb.BatchCountResponse, error) {
+	counts := make([]int64, len(req.GetRequests()))
+	for i, r := range req.GetRequests() {
+		count, err := s.store.Get(r.GetKey(), r.GetStart())
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		counts[i] = count
+	}
+	return &pb.BatchCountResponse{Counts: counts}, nil
+}
+
+// Client is a Datastore backed by a remote Datastore service.
+type Client struct {
+	client  pb.DatastoreClient
+	timeout time.Duration
+}
+
+// NewClient creates a new client on conn, each call of which will fail
+// if it does not complete within timeout. Zero timeout means no deadline.
+func NewClient(conn grpc.ClientConnInterface, timeout time.Duration) *Client {
+	return &Client{
+		client:  pb.NewDatastoreClient(conn),
+		timeout: timeout,
+	}
+}
+
+// Add adds delta to the count of the window represented by start, and
+// returns the new count.
+func (c *Client) Add(key string, start, delta int64) (int64, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.Add(ctx, &pb.AddRequest{Key: key, Start: start, Delta: delta})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetCount(), nil
+}
+
+// Get returns the count of the window represented by start.
+func (c *Client) Get(key string, start int64) (int64, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.Get(ctx, &pb.GetRequest{Key: key, Start: start})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetCount(), nil
+}
+
+// BatchAdd performs multiple Adds in one round trip, and returns the new
+// counts in the same order.
+func (c *Client) BatchAdd(reqs []*pb.AddRequest) ([]int64, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.BatchAdd(ctx, &pb.BatchAddRequest{Requests: reqs})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetCounts(), nil
+}
+
+// BatchGet performs multiple Gets in one round trip, and returns the counts
+// in the same order.
+func (c *Client) BatchGet(reqs []*pb.GetRequest) ([]int64, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+
+	resp, err := c.client.BatchGet(ctx, &pb.BatchGetRequest{Requests: reqs})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetCounts(), nil
+}
+
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+// The metadata key and scheme of the token used by TokenAuth and WithToken.
+const (
+	authorizationKey = "authorization"
+	bearerPrefix     = "Bearer "
+)
+
+// TokenAuth returns a server interceptor that rejects the calls not carrying
+// the bearer token (see WithToken).
+func TokenAuth(token string) grpc.UnaryServerInterceptor {
+	want := []byte(bearerPrefix + token)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get(authorizationKey) {
+			if subtle.ConstantTimeCompare([]byte(v), want) == 1 {
+				return handler(ctx, req)
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+}
+
+// WithToken returns a client interceptor that attaches the bearer token to
+// every call.
+func WithToken(token string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, authorizationKey, bearerPrefix+token)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,140 @@
+package grpcstore
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/grpcstore/pb"
+	"github.com/RussellLuo/slidingwindow/httpstore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type slowStore struct {
+	sw.Datastore
+	delay time.Duration
+}
+
+func (s slowStore) Add(key string, start, delta int64) (int64, error) {
+	time.Sleep(s.delay)
+	return s.Datastore.Add(key, start, delta)
+}
+
+// serve serves store on an in-memory connection, and returns a connected
+// client connection with the given options.
+func serve(t *testing.T, store sw.Datastore, serverOpts []grpc.ServerOption, dialOpts ...grpc.DialOption) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(serverOpts...)
+	pb.RegisterDatastoreServer(s, NewServer(store))
+	go s.Serve(lis) // nolint:errcheck
+	t.Cleanup(s.Stop)
+
+	dialOpts = append(dialOpts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.NewClient("passthrough:///bufnet", dialOpts...)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestClient(t *testing.T) {
+	c := NewClient(serve(t, httpstore.NewMemStore(), nil), time.Second)
+
+	if got, err := c.Add("a", 1, 3); err != nil || got != 3 {
+		t.Errorf("Add: got (%d, %v), want: (3, <nil>)", got, err)
+	}
+	if got, err := c.Get("a", 1); err != nil || got != 3 {
+		t.Errorf("Get: got (%d, %v), want: (3, <nil>)", got, err)
+	}
+
+	counts, err := c.BatchAdd([]*pb.AddRequest{
+		{Key: "a", Start: 1, Delta: -1},
+		{Key: "b", Start: 1, Delta: 5},
+	})
+	if err != nil || len(counts) != 2 || counts[0] != 2 || counts[1] != 5 {
+		t.Errorf("BatchAdd: got (%v, %v), want: ([2 5], <nil>)", counts, err)
+	}
+
+	counts, err = c.BatchGet([]*pb.GetRequest{
+		{Key: "a", Start: 1},
+		{Key: "b", Start: 1},
+		{Key: "b", Start: 2},
+	})
+	if err != nil || len(counts) != 3 || counts[0] != 2 || counts[1] != 5 || counts[2] != 0 {
+		t.Errorf("BatchGet: got (%v, %v), want: ([2 5 0], <nil>)", counts, err)
+	}
+}
+
+func TestClient_Deadline(t *testing.T) {
+	store := slowStore{Datastore: httpstore.NewMemStore(), delay: 100 * time.Millisecond}
+	c := NewClient(serve(t, store, nil), 10*time.Millisecond)
+
+	if _, err := c.Add("a", 1, 1); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Add: got err %v, want: %v", err, codes.DeadlineExceeded)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	serverOpts := []grpc.ServerOption{grpc.UnaryInterceptor(TokenAuth("secret"))}
+
+	cases := []struct {
+		name string
+		opts []grpc.DialOption
+		code codes.Code
+	}{
+		{"no token", nil, codes.Unauthenticated},
+		{"wrong token", []grpc.DialOption{grpc.WithUnaryInterceptor(WithToken("guess"))}, codes.Unauthenticated},
+		{"right token", []grpc.DialOption{grpc.WithUnaryInterceptor(WithToken("secret"))}, codes.OK},
+	}
+	for _, cs := range cases {
+		c := NewClient(serve(t, httpstore.NewMemStore(), serverOpts, cs.opts...), time.Second)
+		if _, err := c.Get("a", 1); status.Code(err) != cs.code {
+			t.Errorf("%s: got err %v, want: %v", cs.name, err, cs.code)
+		}
+	}
+}
+
+func TestLimiter_Client(t *testing.T) {
+	conn := serve(t, httpstore.NewMemStore(), nil)
+
+	size := time.Second
+	now := time.Now().Truncate(size)
+
+	var limiters []*sw.Limiter
+	for i := 0; i < 2; i++ {
+		store := NewClient(conn, time.Second)
+		lim, stop := sw.NewLimiter(size, 10, func() (sw.Window, sw.StopFunc) {
+			return sw.NewSyncWindow("test", sw.NewBlockingSynchronizer(store, 0))
+		})
+		defer stop()
+		limiters = append(limiters, lim)
+	}
+
+	cases := []struct {
+		lim int
+		n   int64
+		ok  bool
+	}{
+		{0, 6, true},
+		{1, 0, true}, // Sync the changes of limiters[0].
+		{1, 6, false},
+		{1, 4, true},
+	}
+	for _, c := range cases {
+		if ok := limiters[c.lim].AllowN(now, c.n); ok != c.ok {
+			t.Errorf("limiters[%d].AllowN(%v) = %v, want: %v", c.lim, c.n, ok, c.ok)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: datastore.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AddRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Start         int64                  `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	Delta         int64                  `protobuf:"varint,3,opt,name=delta,proto3" json:"delta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddRequest) Reset() {
+	*x = AddRequest{}
+	mi := &file_datastore_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddRequest) ProtoMessage() {}
+
+func (x *AddRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_datastore_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddRequest.ProtoReflect.Descriptor instead.
+func (*AddRequest) Descriptor() ([]byte, []int) {
+	return file_datastore_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AddRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *AddRequest) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *AddRequest) GetDelta() int64 {
+	if x != nil {
+		return x.Delta
+	}
+	return 0
+}
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Start         int64                  `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_datastore_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_datastore_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_datastore_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *GetRequest) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+type CountResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int64                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountResponse) Reset() {
+	*x = CountResponse{}
+	mi := &file_datastore_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountResponse) ProtoMessage() {}
+
+func (x *CountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_datastore_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountResponse.ProtoReflect.Descriptor instead.
+func (*CountResponse) Descriptor() ([]byte, []int) {
+	return file_datastore_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CountResponse) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type BatchAddRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*AddRequest          `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchAddRequest) Reset() {
+	*x = BatchAddRequest{}
+	mi := &file_datastore_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchAddRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchAddRequest) ProtoMessage() {}
+
+func (x *BatchAddRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_datastore_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchAddRequest.ProtoReflect.Descriptor instead.
+func (*BatchAddRequest) Descriptor() ([]byte, []int) {
+	return file_datastore_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchAddRequest) GetRequests() []*AddRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type BatchGetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*GetRequest          `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetRequest) Reset() {
+	*x = BatchGetRequest{}
+	mi := &file_datastore_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetRequest) ProtoMessage() {}
+
+func (x *BatchGetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_datastore_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetRequest) Descriptor() ([]byte, []int) {
+	return file_datastore_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchGetRequest) GetRequests() []*GetRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type BatchCountResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Counts        []int64                `protobuf:"varint,1,rep,packed,name=counts,proto3" json:"counts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchCountResponse) Reset() {
+	*x = BatchCountResponse{}
+	mi := &file_datastore_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchCountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchCountResponse) ProtoMessage() {}
+
+func (x *BatchCountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_datastore_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchCountResponse.ProtoReflect.Descriptor instead.
+func (*BatchCountResponse) Descriptor() ([]byte, []int) {
+	return file_datastore_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchCountResponse) GetCounts() []int64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+var File_datastore_proto protoreflect.FileDescriptor
+
+const file_datastore_proto_rawDesc = "" +
+	"\n" +
+	"\x0fdatastore.proto\x12\x17slidingwindow.datastore\"J\n" +
+	"\n" +
+	"AddRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x03R\x05start\x12\x14\n" +
+	"\x05delta\x18\x03 \x01(\x03R\x05delta\"4\n" +
+	"\n" +
+	"GetRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x03R\x05start\"%\n" +
+	"\rCountResponse\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x03R\x05count\"R\n" +
+	"\x0fBatchAddRequest\x12?\n" +
+	"\brequests\x18\x01 \x03(\v2#.slidingwindow.datastore.AddRequestR\brequests\"R\n" +
+	"\x0fBatchGetRequest\x12?\n" +
+	"\brequests\x18\x01 \x03(\v2#.slidingwindow.datastore.GetRequestR\brequests\",\n" +
+	"\x12BatchCountResponse\x12\x16\n" +
+	"\x06counts\x18\x01 \x03(\x03R\x06counts2\xf9\x02\n" +
+	"\tDatastore\x12R\n" +
+	"\x03Add\x12#.slidingwindow.datastore.AddRequest\x1a&.slidingwindow.datastore.CountResponse\x12R\n" +
+	"\x03Get\x12#.slidingwindow.datastore.GetRequest\x1a&.slidingwindow.datastore.CountResponse\x12a\n" +
+	"\bBatchAdd\x12(.slidingwindow.datastore.BatchAddRequest\x1a+.slidingwindow.datastore.BatchCountResponse\x12a\n" +
+	"\bBatchGet\x12(.slidingwindow.datastore.BatchGetRequest\x1a+.slidingwindow.datastore.BatchCountResponseB2Z0github.com/RussellLuo/slidingwindow/grpcstore/pbb\x06proto3"
+
+var (
+	file_datastore_proto_rawDescOnce sync.Once
+	file_datastore_proto_rawDescData []byte
+)
+
+func file_datastore_proto_rawDescGZIP() []byte {
+	file_datastore_proto_rawDescOnce.Do(func() {
+		file_datastore_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_datastore_proto_rawDesc), len(file_datastore_proto_rawDesc)))
+	})
+	return file_datastore_proto_rawDescData
+}
+
+var file_datastore_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_datastore_proto_goTypes = []any{
+	(*AddRequest)(nil),         // 0: slidingwindow.datastore.AddRequest
+	(*GetRequest)(nil),         // 1: slidingwindow.datastore.GetRequest
+	(*CountResponse)(nil),      // 2: slidingwindow.datastore.CountResponse
+	(*BatchAddRequest)(nil),    // 3: slidingwindow.datastore.BatchAddRequest
+	(*BatchGetRequest)(nil),    // 4: slidingwindow.datastore.BatchGetRequest
+	(*BatchCountResponse)(nil), // 5: slidingwindow.datastore.BatchCountResponse
+}
+var file_datastore_proto_depIdxs = []int32{
+	0, // 0: slidingwindow.datastore.BatchAddRequest.requests:type_name -> slidingwindow.datastore.AddRequest
+	1, // 1: slidingwindow.datastore.BatchGetRequest.requests:type_name -> slidingwindow.datastore.GetRequest
+	0, // 2: slidingwindow.datastore.Datastore.Add:input_type -> slidingwindow.datastore.AddRequest
+	1, // 3: slidingwindow.datastore.Datastore.Get:input_type -> slidingwindow.datastore.GetRequest
+	3, // 4: slidingwindow.datastore.Datastore.BatchAdd:input_type -> slidingwindow.datastore.BatchAddRequest
+	4, // 5: slidingwindow.datastore.Datastore.BatchGet:input_type -> slidingwindow.datastore.BatchGetRequest
+	2, // 6: slidingwindow.datastore.Datastore.Add:output_type -> slidingwindow.datastore.CountResponse
+	2, // 7: slidingwindow.datastore.Datastore.Get:output_type -> slidingwindow.datastore.CountResponse
+	5, // 8: slidingwindow.datastore.Datastore.BatchAdd:output_type -> slidingwindow.datastore.BatchCountResponse
+	5, // 9: slidingwindow.datastore.Datastore.BatchGet:output_type -> slidingwindow.datastore.BatchCountResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_datastore_proto_init() }
+func file_datastore_proto_init() {
+	if File_datastore_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_datastore_proto_rawDesc), len(file_datastore_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_datastore_proto_goTypes,
+		DependencyIndexes: file_datastore_proto_depIdxs,
+		MessageInfos:      file_datastore_proto_msgTypes,
+	}.Build()
+	File_datastore_proto = out.File
+	file_datastore_proto_goTypes = nil
+	file_datastore_proto_depIdxs = nil
+}
@@ -0,0 +1,255 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: datastore.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Datastore_Add_FullMethodName      = "/slidingwindow.datastore.Datastore/Add"
+	Datastore_Get_FullMethodName      = "/slidingwindow.datastore.Datastore/Get"
+	Datastore_BatchAdd_FullMethodName = "/slidingwindow.datastore.Datastore/BatchAdd"
+	Datastore_BatchGet_FullMethodName = "/slidingwindow.datastore.Datastore/BatchGet"
+)
+
+// DatastoreClient is the client API for Datastore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Datastore mirrors the Datastore interface of slidingwindow, where each
+// window is identified by a key and its start boundary (timestamp in
+// nanoseconds).
+type DatastoreClient interface {
+	// Add adds delta to the count of the window, and returns the new count.
+	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*CountResponse, error)
+	// Get returns the count of the window.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*CountResponse, error)
+	// BatchAdd performs multiple Adds in one round trip, and returns the
+	// new counts in the same order.
+	BatchAdd(ctx context.Context, in *BatchAddRequest, opts ...grpc.CallOption) (*BatchCountResponse, error)
+	// BatchGet performs multiple Gets in one round trip, and returns the
+	// counts in the same order.
+	BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchCountResponse, error)
+}
+
+type datastoreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDatastoreClient(cc grpc.ClientConnInterface) DatastoreClient {
+	return &datastoreClient{cc}
+}
+
+func (c *datastoreClient) Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*CountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CountResponse)
+	err := c.cc.Invoke(ctx, Datastore_Add_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*CountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CountResponse)
+	err := c.cc.Invoke(ctx, Datastore_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) BatchAdd(ctx context.Context, in *BatchAddRequest, opts ...grpc.CallOption) (*BatchCountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchCountResponse)
+	err := c.cc.Invoke(ctx, Datastore_BatchAdd_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datastoreClient) BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchCountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchCountResponse)
+	err := c.cc.Invoke(ctx, Datastore_BatchGet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DatastoreServer is the server API for Datastore service.
+// All implementations must embed UnimplementedDatastoreServer
+// for forward compatibility.
+//
+// Datastore mirrors the Datastore interface of slidingwindow, where each
+// window is identified by a key and its start boundary (timestamp in
+// nanoseconds).
+type DatastoreServer interface {
+	// Add adds delta to the count of the window, and returns the new count.
+	Add(context.Context, *AddRequest) (*CountResponse, error)
+	// Get returns the count of the window.
+	Get(context.Context, *GetRequest) (*CountResponse, error)
+	// BatchAdd performs multiple Adds in one round trip, and returns the
+	// new counts in the same order.
+	BatchAdd(context.Context, *BatchAddRequest) (*BatchCountResponse, error)
+	// BatchGet performs multiple Gets in one round trip, and returns the
+	// counts in the same order.
+	BatchGet(context.Context, *BatchGetRequest) (*BatchCountResponse, error)
+	mustEmbedUnimplementedDatastoreServer()
+}
+
+// UnimplementedDatastoreServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDatastoreServer struct{}
+
+func (UnimplementedDatastoreServer) Add(context.Context, *AddRequest) (*CountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedDatastoreServer) Get(context.Context, *GetRequest) (*CountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedDatastoreServer) BatchAdd(context.Context, *BatchAddRequest) (*BatchCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchAdd not implemented")
+}
+func (UnimplementedDatastoreServer) BatchGet(context.Context, *BatchGetRequest) (*BatchCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGet not implemented")
+}
+func (UnimplementedDatastoreServer) mustEmbedUnimplementedDatastoreServer() {}
+func (UnimplementedDatastoreServer) testEmbeddedByValue()                   {}
+
+// UnsafeDatastoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DatastoreServer will
+// result in compilation errors.
+type UnsafeDatastoreServer interface {
+	mustEmbedUnimplementedDatastoreServer()
+}
+
+func RegisterDatastoreServer(s grpc.ServiceRegistrar, srv DatastoreServer) {
+	// If the following call pancis, it indicates UnimplementedDatastoreServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Datastore_ServiceDesc, srv)
+}
+
+func _Datastore_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_Add_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).Add(ctx, req.(*AddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_BatchAdd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchAddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).BatchAdd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_BatchAdd_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).BatchAdd(ctx, req.(*BatchAddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datastore_BatchGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatastoreServer).BatchGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datastore_BatchGet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatastoreServer).BatchGet(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Datastore_ServiceDesc is the grpc.ServiceDesc for Datastore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Datastore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "slidingwindow.datastore.Datastore",
+	HandlerType: (*DatastoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Add",
+			Handler:    _Datastore_Add_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Datastore_Get_Handler,
+		},
+		{
+			MethodName: "BatchAdd",
+			Handler:    _Datastore_BatchAdd_Handler,
+		},
+		{
+			MethodName: "BatchGet",
+			Handler:    _Datastore_BatchGet_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "datastore.proto",
+}
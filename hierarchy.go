@@ -0,0 +1,94 @@
+package slidingwindow
+
+import (
+	"sort"
+	"time"
+)
+
+// Level represents one level of hierarchical quotas (e.g. organizations,
+// users or API keys), whose limiters may be backed by any kind of windows.
+type Level struct {
+	Name    string
+	Limiter *KeyedLimiter
+}
+
+// Hierarchy represents nested quotas, where an event consumes from the
+// limiters of all the levels.
+type Hierarchy struct {
+	levels []Level
+}
+
+// NewHierarchy creates a new hierarchy with the given levels, which will
+// be checked in order (e.g. from API keys to organizations).
+func NewHierarchy(levels ...Level) *Hierarchy {
+	return &Hierarchy{levels: levels}
+}
+
+// Allow is shorthand for AllowN(keys, time.Now(), 1).
+func (h *Hierarchy) Allow(keys ...string) (bool, string) {
+	return h.AllowN(keys, time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at time now, where keys[i]
+// is the key at the i-th level. If not, it also returns the name of the
+// level that denied the events.
+//
+// The limiters of the keys are locked together (in a fixed order, to avoid
+// deadlocks) during the decision, so the decision is atomic with respect to
+// other callers of hierarchies sharing any of the keys. The events are
+// consumed from the levels one by one, and are given back to the consumed
+// levels if any level denies them, before the limiters are unlocked.
+//
+// The events are always denied, with an empty level name, if the number of
+// keys does not match the number of levels.
+func (h *Hierarchy) AllowN(keys []string, now time.Time, n int64) (bool, string) {
+	if len(keys) != len(h.levels) {
+		return false, ""
+	}
+
+	lims := make([]*Limiter, len(h.levels))
+	for i, level := range h.levels {
+		lims[i] = level.Limiter.Limiter(keys[i])
+	}
+	unlock := lockAll(lims)
+	defer unlock()
+
+	for i, level := range h.levels {
+		if lims[i].consumeLocked(now, n) {
+			continue
+		}
+
+		// Give back the events to all the consumed levels.
+		for j := 0; j < i; j++ {
+			lims[j].curr.AddCount(-n)
+		}
+		return false, level.Name
+	}
+
+	return true, ""
+}
+
+// lockAll locks the distinct limiters of lims in the order of their ids,
+// and returns a function to unlock them.
+func lockAll(lims []*Limiter) (unlock func()) {
+	sorted := make([]*Limiter, 0, len(lims))
+	seen := make(map[*Limiter]bool, len(lims))
+	for _, lim := range lims {
+		if !seen[lim] {
+			seen[lim] = true
+			sorted = append(sorted, lim)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].id < sorted[j].id
+	})
+
+	for _, lim := range sorted {
+		lim.mu.Lock()
+	}
+	return func() {
+		for _, lim := range sorted {
+			lim.mu.Unlock()
+		}
+	}
+}
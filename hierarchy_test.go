@@ -0,0 +1,146 @@
+package slidingwindow
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHierarchy_AllowN(t *testing.T) {
+	store := newMemDatastore()
+	newLocalWindow := func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	}
+	newSyncWindow := func(key string) (Window, StopFunc) {
+		return NewSyncWindow(key, NewBlockingSynchronizer(store, 0))
+	}
+
+	keys := NewKeyedLimiter(size, 3, newLocalWindow)
+	users := NewKeyedLimiter(size, 5, newSyncWindow)
+	orgs := NewKeyedLimiter(size, 10, newLocalWindow)
+	defer users.Stop()
+
+	h := NewHierarchy(
+		Level{Name: "key", Limiter: keys},
+		Level{Name: "user", Limiter: users},
+		Level{Name: "org", Limiter: orgs},
+	)
+
+	cases := []struct {
+		keys   []string
+		t      caseArg
+		denied string
+	}{
+		{[]string{"k1", "u1", "o1"}, caseArg{t0, 3, true}, ""},
+		{[]string{"k1", "u1", "o1"}, caseArg{t1, 1, false}, "key"},
+		{[]string{"k2", "u1", "o1"}, caseArg{t1, 2, true}, ""},
+		{[]string{"k3", "u1", "o1"}, caseArg{t1, 1, false}, "user"},
+		{[]string{"k4", "u2", "o1"}, caseArg{t2, 3, true}, ""},
+		{[]string{"k5", "u3", "o1"}, caseArg{t2, 3, false}, "org"},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok, denied := h.AllowN(c.keys, c.t.t, c.t.n)
+			if ok != c.t.ok || denied != c.denied {
+				t.Errorf("h.AllowN(%v, %v, %v) = (%v, %q), want: (%v, %q)",
+					c.keys, c.t.t, c.t.n, ok, denied, c.t.ok, c.denied)
+			}
+		})
+	}
+
+	// The events denied by "user" and "org" have been refunded from
+	// the lower levels.
+	for _, c := range []struct {
+		lim *KeyedLimiter
+		key string
+	}{
+		{keys, "k3"},
+		{keys, "k5"},
+		{users, "u3"},
+	} {
+		if got := c.lim.Limiter(c.key).Snapshot().CurrCount; got != 0 {
+			t.Errorf("count of %q = %d, want: 0", c.key, got)
+		}
+	}
+
+	// The refund is also synced to the datastore.
+	users.AllowN("u3", t3, 0)
+	if count, _ := store.Get("u3", t0.UnixNano()); count != 0 {
+		t.Errorf("store.Get(u3) = %d, want: 0", count)
+	}
+}
+
+func TestHierarchy_AllowN_Concurrent(t *testing.T) {
+	newLocalWindow := func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	}
+	users := NewKeyedLimiter(size, 50, newLocalWindow)
+	orgs := NewKeyedLimiter(size, 100, newLocalWindow)
+	h := NewHierarchy(
+		Level{Name: "user", Limiter: users},
+		Level{Name: "org", Limiter: orgs},
+	)
+
+	// The decisions are atomic, so exactly the limit of "org" is permitted,
+	// and no event is denied due to the counts being given back by others.
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if ok, _ := h.AllowN([]string{user, "o1"}, t0, 1); ok {
+					atomic.AddInt64(&allowed, 1)
+				}
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	if allowed != 100 {
+		t.Errorf("allowed = %d, want: 100", allowed)
+	}
+	if got := orgs.Limiter("o1").Count(t0); got != allowed {
+		t.Errorf("count of o1 = %d, want: %d", got, allowed)
+	}
+
+	// Mismatched keys are denied instead of panicking.
+	if ok, denied := h.AllowN([]string{"u1"}, t0, 1); ok || denied != "" {
+		t.Errorf("h.AllowN([u1]) = (%v, %q), want: (false, \"\")", ok, denied)
+	}
+}
+
+func TestHierarchy_AllowN_LockOrder(t *testing.T) {
+	newLocalWindow := func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	}
+	users := NewKeyedLimiter(size, 1000, newLocalWindow)
+	orgs := NewKeyedLimiter(size, 1000, newLocalWindow)
+
+	// The levels of the two hierarchies are in opposite orders, which must
+	// not lead to a deadlock.
+	h1 := NewHierarchy(Level{Name: "user", Limiter: users}, Level{Name: "org", Limiter: orgs})
+	h2 := NewHierarchy(Level{Name: "org", Limiter: orgs}, Level{Name: "user", Limiter: users})
+
+	var wg sync.WaitGroup
+	for _, f := range []func(){
+		func() { h1.AllowN([]string{"u1", "o1"}, t0, 1) },
+		func() { h2.AllowN([]string{"o1", "u1"}, t0, 1) },
+	} {
+		wg.Add(1)
+		go func(f func()) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				f()
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	if got := orgs.Limiter("o1").Count(t0); got != 1000 {
+		t.Errorf("count of o1 = %d, want: 1000", got)
+	}
+}
@@ -0,0 +1,296 @@
+// Package httpstore exposes a Datastore over HTTP, so that services which
+// can not embed the client of the central datastore (e.g. Redis) are still
+// able to share counters with the others.
+//
+// The server side is Handler, which wraps any Datastore:
+//
+//	http.Handle("/store", httpstore.NewHandler(store))
+//
+// The client side is Client, which implements the Datastore interface:
+//
+//	store := httpstore.NewClient("http://counter-service/store", nil)
+//	lim, stop := sw.NewLimiter(size, limit, func() (sw.Window, sw.StopFunc) {
+//	    return sw.NewSyncWindow("test", sw.NewBlockingSynchronizer(store, 500*time.Millisecond))
+//	})
+package httpstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// The kinds of operations. List and delete are only supported if the
+// store served by Handler is a ListDatastore.
+const (
+	opAdd    = "add"
+	opGet    = "get"
+	opList   = "list"
+	opDelete = "delete"
+)
+
+// op is the serialization format of one call. For list, Key is the prefix.
+type op struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Start int64  `json:"start"`
+	Delta int64  `json:"delta,omitempty"`
+}
+
+// result is the serialization format of the result of one op.
+type result struct {
+	Count   int64            `json:"count"`
+	Windows []sw.WindowCount `json:"windows,omitempty"` // only for list
+	Error   string           `json:"error,omitempty"`
+}
+
+var errNotSupported = errors.New("httpstore: operation not supported by the store")
+
+// Handler serves the operations of a Datastore. It accepts a POST request
+// whose body is a JSON array of operations, and responds with a JSON array
+// of their results in the same order.
+type Handler struct {
+	store sw.Datastore
+}
+
+// NewHandler creates a new handler that serves the operations of store.
+func NewHandler(store sw.Datastore) *Handler {
+	return &Handler{store: store}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ops []op
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]result, len(ops))
+	for i, o := range ops {
+		var err error
+		switch o.Op {
+		case opAdd:
+			results[i].Count, err = h.store.Add(o.Key, o.Start, o.Delta)
+		case opGet:
+			results[i].Count, err = h.store.Get(o.Key, o.Start)
+		case opList:
+			if ls, ok := h.store.(sw.ListDatastore); ok {
+				results[i].Windows, err = ls.List(o.Key)
+			} else {
+				err = errNotSupported
+			}
+		case opDelete:
+			if ls, ok := h.store.(sw.ListDatastore); ok {
+				err = ls.Delete(o.Key, o.Start)
+			} else {
+				err = errNotSupported
+			}
+		default:
+			err = fmt.Errorf("unknown op %q", o.Op)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results) // nolint:errcheck
+}
+
+// call is a pending operation of Client.
+type call struct {
+	op     op
+	result result
+	err    error
+
+	// done receives true once the result is filled, or false if the caller
+	// is to send the queued operations itself.
+	done chan bool
+}
+
+// Client is a Datastore backed by a remote Handler.
+//
+// Concurrent calls are batched: the calls made while a request is in
+// flight are sent together in the next request, so the number of round
+// trips is bounded by the concurrency rather than by the number of windows.
+type Client struct {
+	url    string
+	client *http.Client
+
+	// The maximum number of operations sent in one request.
+	MaxBatch int
+
+	mu       sync.Mutex
+	pending  []*call
+	flushing bool
+}
+
+// defaultTimeout is the timeout of the requests sent by the default client.
+const defaultTimeout = 5 * time.Second
+
+// NewClient creates a new client that sends requests to the handler at url.
+// If client is nil, a client whose transport keeps enough idle connections
+// alive for the batched requests, and whose requests time out after five
+// seconds, will be used.
+func NewClient(url string, client *http.Client) *Client {
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = 16
+		client = &http.Client{Transport: transport, Timeout: defaultTimeout}
+	}
+	return &Client{
+		url:      url,
+		client:   client,
+		MaxBatch: 100,
+	}
+}
+
+// Add adds delta to the count of the window represented by start, and
+// returns the new count.
+func (c *Client) Add(key string, start, delta int64) (int64, error) {
+	r, err := c.do(op{Op: opAdd, Key: key, Start: start, Delta: delta})
+	return r.Count, err
+}
+
+// Get returns the count of the window represented by start.
+func (c *Client) Get(key string, start int64) (int64, error) {
+	r, err := c.do(op{Op: opGet, Key: key, Start: start})
+	return r.Count, err
+}
+
+// List returns the windows whose keys have the given prefix, sorted by key
+// and then by start. It fails if the remote store is not a ListDatastore.
+func (c *Client) List(prefix string) ([]sw.WindowCount, error) {
+	r, err := c.do(op{Op: opList, Key: prefix})
+	return r.Windows, err
+}
+
+// Delete deletes the window represented by start. It fails if the remote
+// store is not a ListDatastore.
+func (c *Client) Delete(key string, start int64) error {
+	_, err := c.do(op{Op: opDelete, Key: key, Start: start})
+	return err
+}
+
+// do queues o, and waits for its result. If no request is in flight, the
+// caller itself sends the queued operations, until its own operation is
+// done, and then hands this role over to the next caller in the queue.
+func (c *Client) do(o op) (result, error) {
+	cl := &call{op: o, done: make(chan bool, 1)}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, cl)
+	if c.flushing {
+		c.mu.Unlock()
+		if <-cl.done {
+			return cl.result, cl.err
+		}
+		// The role of sending has been handed over to us.
+	} else {
+		c.flushing = true
+		c.mu.Unlock()
+	}
+
+	c.flush(cl)
+	return cl.result, cl.err
+}
+
+// flush sends the queued operations in batches, until own is done. The
+// rest of the queue, if any, is left to the caller of the first operation
+// in it.
+func (c *Client) flush(own *call) {
+	done := false
+	for {
+		c.mu.Lock()
+		n := len(c.pending)
+		if n == 0 {
+			c.flushing = false
+			c.mu.Unlock()
+			return
+		}
+		if done {
+			next := c.pending[0]
+			c.mu.Unlock()
+			next.done <- false
+			return
+		}
+		if c.MaxBatch > 0 && n > c.MaxBatch {
+			n = c.MaxBatch
+		}
+		batch := c.pending[:n:n]
+		c.pending = c.pending[n:]
+		c.mu.Unlock()
+
+		c.send(batch)
+		for _, cl := range batch {
+			if cl == own {
+				done = true
+				continue
+			}
+			cl.done <- true
+		}
+	}
+}
+
+// send sends batch in one request, and fills the results of the calls.
+func (c *Client) send(batch []*call) {
+	results, err := c.post(batch)
+	if err == nil && len(results) != len(batch) {
+		err = fmt.Errorf("httpstore: got %d results for %d ops", len(results), len(batch))
+	}
+	for i, cl := range batch {
+		switch {
+		case err != nil:
+			cl.err = err
+		case results[i].Error != "":
+			cl.err = errors.New(results[i].Error)
+		default:
+			cl.result = results[i]
+		}
+	}
+}
+
+func (c *Client) post(batch []*call) ([]result, error) {
+	ops := make([]op, len(batch))
+	for i, cl := range batch {
+		ops[i] = cl.op
+	}
+	body, err := json.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Drain the body so that the connection can be reused.
+		io.Copy(ioutil.Discard, resp.Body) // nolint:errcheck
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("httpstore: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var results []result
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
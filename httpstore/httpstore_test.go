@@ -0,0 +1,265 @@
+package httpstore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+type errStore struct{}
+
+func (errStore) Add(key string, start, delta int64) (int64, error) {
+	return 0, errors.New("unavailable")
+}
+
+func (errStore) Get(key string, start int64) (int64, error) {
+	return 0, errors.New("unavailable")
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(NewHandler(NewMemStore()))
+	defer server.Close()
+
+	c := NewClient(server.URL, nil)
+
+	cases := []struct {
+		add   bool
+		key   string
+		start int64
+		delta int64
+		want  int64
+	}{
+		{true, "a", 1, 3, 3},
+		{true, "a", 1, -1, 2},
+		{true, "a", 2, 5, 5},
+		{false, "a", 1, 0, 2},
+		{false, "b", 1, 0, 0},
+	}
+	for _, cs := range cases {
+		var got int64
+		var err error
+		if cs.add {
+			got, err = c.Add(cs.key, cs.start, cs.delta)
+		} else {
+			got, err = c.Get(cs.key, cs.start)
+		}
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if got != cs.want {
+			t.Errorf("%+v: got %d, want: %d", cs, got, cs.want)
+		}
+	}
+}
+
+func TestClient_ListDelete(t *testing.T) {
+	server := httptest.NewServer(NewHandler(NewMemStore()))
+	defer server.Close()
+
+	c := NewClient(server.URL, nil)
+	adds := []sw.WindowCount{
+		{Key: "b", Start: 1, Count: 2},
+		{Key: "a", Start: 2, Count: 3},
+		{Key: "a", Start: 1, Count: 4},
+		{Key: "c", Start: 1, Count: 5},
+	}
+	for _, w := range adds {
+		if _, err := c.Add(w.Key, w.Start, w.Count); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := c.Delete("c", 1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	got, err := c.List("")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := []sw.WindowCount{{Key: "a", Start: 1, Count: 4}, {Key: "a", Start: 2, Count: 3}, {Key: "b", Start: 1, Count: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %v, want: %v", got, want)
+	}
+
+	got, _ = c.List("b")
+	if want := want[2:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("List(b): got %v, want: %v", got, want)
+	}
+
+	server = httptest.NewServer(NewHandler(errStore{}))
+	defer server.Close()
+
+	c = NewClient(server.URL, nil)
+	if _, err := c.List(""); err == nil || err.Error() != errNotSupported.Error() {
+		t.Errorf("List: got err %v, want: %v", err, errNotSupported)
+	}
+}
+
+func TestClient_Batching(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	handler := NewHandler(NewMemStore())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, nil)
+	c.MaxBatch = 10
+
+	const calls = 50
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Add("a", 1, 1); err != nil {
+				t.Errorf("err: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, _ := c.Get("a", 1); got != calls {
+		t.Errorf("Count: got %d, want: %d", got, calls)
+	}
+	if requests >= calls {
+		t.Errorf("Requests: got %d, want: < %d", requests, calls)
+	}
+}
+
+func TestClient_HandOff(t *testing.T) {
+	handler := NewHandler(NewMemStore())
+	arrivedC := make(chan struct{})
+	releaseC := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrivedC <- struct{}{}
+		<-releaseC
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, nil)
+	add := func(key string) chan struct{} {
+		doneC := make(chan struct{})
+		go func() {
+			defer close(doneC)
+			if _, err := c.Add(key, 1, 1); err != nil {
+				t.Errorf("err: %v", err)
+			}
+		}()
+		return doneC
+	}
+
+	// The call of "a" is in flight, while the call of "b" is queued.
+	aDoneC := add("a")
+	<-arrivedC
+	bDoneC := add("b")
+	for {
+		c.mu.Lock()
+		n := len(c.pending)
+		c.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The caller of "a" returns once its own call is done, and hands the
+	// sending of "b" over to the caller of "b".
+	releaseC <- struct{}{}
+	<-arrivedC
+	select {
+	case <-aDoneC:
+	case <-time.After(time.Second):
+		t.Errorf("the caller of a is still sending the queued calls")
+	}
+
+	releaseC <- struct{}{}
+	<-bDoneC
+	<-aDoneC
+}
+
+func TestClient_Error(t *testing.T) {
+	server := httptest.NewServer(NewHandler(errStore{}))
+	defer server.Close()
+
+	c := NewClient(server.URL, nil)
+	if _, err := c.Add("a", 1, 1); err == nil || err.Error() != "unavailable" {
+		t.Errorf("Add: got err %v, want: unavailable", err)
+	}
+
+	server = httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c = NewClient(server.URL, nil)
+	if _, err := c.Get("a", 1); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Get: got err %v, want: 404", err)
+	}
+}
+
+func TestLimiter_Client(t *testing.T) {
+	server := httptest.NewServer(NewHandler(NewMemStore()))
+	defer server.Close()
+
+	size := time.Second
+	now := time.Now().Truncate(size)
+
+	var limiters []*sw.Limiter
+	for i := 0; i < 2; i++ {
+		store := NewClient(server.URL, nil)
+		lim, stop := sw.NewLimiter(size, 10, func() (sw.Window, sw.StopFunc) {
+			return sw.NewSyncWindow("test", sw.NewBlockingSynchronizer(store, 0))
+		})
+		defer stop()
+		limiters = append(limiters, lim)
+	}
+
+	cases := []struct {
+		lim int
+		n   int64
+		ok  bool
+	}{
+		{0, 6, true},
+		{1, 0, true}, // Sync the changes of limiters[0].
+		{1, 6, false},
+		{1, 4, true},
+	}
+	for _, c := range cases {
+		if ok := limiters[c.lim].AllowN(now, c.n); ok != c.ok {
+			t.Errorf("limiters[%d].AllowN(%v) = %v, want: %v", c.lim, c.n, ok, c.ok)
+		}
+	}
+}
+
+func TestHandler_BadRequest(t *testing.T) {
+	h := NewHandler(NewMemStore())
+
+	cases := []struct {
+		method string
+		body   string
+		code   int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "{", http.StatusBadRequest},
+		{http.MethodPost, `[{"op":"mul","key":"a","start":1}]`, http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(c.method, "/", strings.NewReader(c.body)))
+		if w.Code != c.code {
+			t.Errorf("%s %q: got %d, want: %d", c.method, c.body, w.Code, c.code)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package httpstore
+
+import (
+	"strings"
+	"sync"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// window identifies the window represented by start, of the given key.
+type window struct {
+	key   string
+	start int64
+}
+
+// MemStore is a Datastore keeping the counts in memory, which is suitable
+// to be served by Handler as a small counting service (e.g. in staging).
+type MemStore struct {
+	mu     sync.RWMutex
+	counts map[window]int64
+}
+
+// NewMemStore creates a new empty in-memory store.
+func NewMemStore() *MemStore {
+	return &MemStore{counts: make(map[window]int64)}
+}
+
+// Add adds delta to the count of the window represented by start, and
+// returns the new count.
+func (s *MemStore) Add(key string, start, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := window{key: key, start: start}
+	s.counts[w] += delta
+	return s.counts[w], nil
+}
+
+// Get returns the count of the window represented by start.
+func (s *MemStore) Get(key string, start int64) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.counts[window{key: key, start: start}], nil
+}
+
+// List returns the windows whose keys have the given prefix, sorted by key
+// and then by start.
+func (s *MemStore) List(prefix string) ([]sw.WindowCount, error) {
+	s.mu.RLock()
+	var counts []sw.WindowCount
+	for w, count := range s.counts {
+		if strings.HasPrefix(w.key, prefix) {
+			counts = append(counts, sw.WindowCount{Key: w.key, Start: w.start, Count: count})
+		}
+	}
+	s.mu.RUnlock()
+
+	sw.SortWindowCounts(counts)
+	return counts, nil
+}
+
+// Delete deletes the window represented by start.
+func (s *MemStore) Delete(key string, start int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.counts, window{key: key, start: start})
+	return nil
+}
+
+// DeleteBefore discards the windows started before start, which should be
+// called periodically since the store never expires windows by itself.
+func (s *MemStore) DeleteBefore(start int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for w := range s.counts {
+		if w.start < start {
+			delete(s.counts, w)
+		}
+	}
+}
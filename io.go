@@ -0,0 +1,115 @@
+package slidingwindow
+
+import (
+	"context"
+	"io"
+)
+
+// chunkDivisor decides the maximum chunk size, which is a fraction of
+// the limit.
+//
+// A chunk as large as the limit is only permitted once the sliding window
+// is empty, i.e. one whole window size after the previous chunk, which
+// halves the rate. Worse, if the limiter is shared (e.g. by SyncWindow),
+// such a chunk may never be permitted while others keep taking the bytes.
+const chunkDivisor = 16
+
+// chunkSize returns the size of the next chunk to read or write, which
+// never exceeds a small fraction of the limit of lim (if positive), so
+// that it can be permitted soon.
+func chunkSize(lim *Limiter, n int) int {
+	limit := lim.Limit()
+	if limit < 1 {
+		// Let WaitN report the error.
+		return 1
+	}
+	max := limit / chunkDivisor
+	if max < 1 {
+		max = 1
+	}
+	if int64(n) > max {
+		return int(max)
+	}
+	return n
+}
+
+// Reader is an io.Reader whose bandwidth is limited by a limiter, where
+// each byte counts as one event.
+type Reader struct {
+	ctx context.Context
+	r   io.Reader
+	lim *Limiter
+}
+
+// NewReader returns a reader that reads from r, at the rate limited by lim.
+//
+// Since lim may be backed by SyncWindow, the bandwidth can be limited across
+// the whole cluster.
+func NewReader(r io.Reader, lim *Limiter) *Reader {
+	return NewReaderContext(context.Background(), r, lim)
+}
+
+// NewReaderContext is like NewReader, but the reads stop waiting for the
+// limiter, and return the error of ctx, once ctx is done.
+func NewReaderContext(ctx context.Context, r io.Reader, lim *Limiter) *Reader {
+	return &Reader{ctx: ctx, r: r, lim: lim}
+}
+
+// Read reads at most a chunk (a small fraction of the limit) into p, and
+// then waits until the read bytes are permitted by the limiter.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return r.r.Read(p)
+	}
+
+	n, err := r.r.Read(p[:chunkSize(r.lim, len(p))])
+	if n > 0 {
+		if werr := r.lim.WaitN(r.ctx, int64(n)); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Writer is an io.Writer whose bandwidth is limited by a limiter, where
+// each byte counts as one event.
+type Writer struct {
+	ctx context.Context
+	w   io.Writer
+	lim *Limiter
+}
+
+// NewWriter returns a writer that writes to w, at the rate limited by lim.
+//
+// Since lim may be backed by SyncWindow, the bandwidth can be limited across
+// the whole cluster.
+func NewWriter(w io.Writer, lim *Limiter) *Writer {
+	return NewWriterContext(context.Background(), w, lim)
+}
+
+// NewWriterContext is like NewWriter, but the writes stop waiting for the
+// limiter, and return the error of ctx, once ctx is done.
+func NewWriterContext(ctx context.Context, w io.Writer, lim *Limiter) *Writer {
+	return &Writer{ctx: ctx, w: w, lim: lim}
+}
+
+// Write writes p in chunks of at most a small fraction of the limit, and
+// waits until each chunk is permitted by the limiter before writing it.
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		chunk = chunk[:chunkSize(w.lim, len(chunk))]
+
+		if err := w.lim.WaitN(w.ctx, int64(len(chunk))); err != nil {
+			return written, err
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,117 @@
+package slidingwindow
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// chunkRecorder records the size of each chunk written to it.
+type chunkRecorder struct {
+	bytes.Buffer
+	chunks []int
+}
+
+func (r *chunkRecorder) Write(p []byte) (int, error) {
+	r.chunks = append(r.chunks, len(p))
+	return r.Buffer.Write(p)
+}
+
+func newIOLimiter() *Limiter {
+	// 100 bytes per 100ms.
+	lim, _ := NewLimiter(d, 100, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+	return lim
+}
+
+func TestLimiter_WaitN(t *testing.T) {
+	lim := newIOLimiter()
+
+	if err := lim.WaitN(context.Background(), 101); err == nil {
+		t.Errorf("lim.WaitN(101) err = nil, want: non-nil")
+	}
+
+	if err := lim.WaitN(context.Background(), 100); err != nil {
+		t.Fatalf("lim.WaitN(100) err = %v, want: nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := lim.WaitN(ctx, 100); err != context.DeadlineExceeded {
+		t.Errorf("lim.WaitN(100) err = %v, want: %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 250)
+	r := NewReader(bytes.NewReader(data), newIOLimiter())
+
+	start := time.Now()
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %d bytes, want: %d", len(got), len(data))
+	}
+	if elapsed := time.Since(start); elapsed < d {
+		t.Errorf("elapsed = %v, want: >= %v", elapsed, d)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 250)
+	rec := new(chunkRecorder)
+	w := NewWriter(rec, newIOLimiter())
+
+	start := time.Now()
+	n, err := io.Copy(w, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(rec.Bytes(), data) {
+		t.Errorf("got %d bytes, want: %d", n, len(data))
+	}
+	if elapsed := time.Since(start); elapsed < d {
+		t.Errorf("elapsed = %v, want: >= %v", elapsed, d)
+	}
+
+	for _, chunk := range rec.chunks {
+		if chunk > 100/chunkDivisor {
+			t.Errorf("got chunk of %d bytes, want: <= %d", chunk, 100/chunkDivisor)
+		}
+	}
+}
+
+func TestWriter_Rate(t *testing.T) {
+	// 300 bytes take at least 200ms at 100 bytes per 100ms, and should not
+	// take much longer than that.
+	data := bytes.Repeat([]byte("x"), 300)
+	w := NewWriter(ioutil.Discard, newIOLimiter())
+
+	start := time.Now()
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*d || elapsed > 4*d {
+		t.Errorf("elapsed = %v, want: within [%v, %v]", elapsed, 2*d, 4*d)
+	}
+}
+
+func TestWriterContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w := NewWriterContext(ctx, ioutil.Discard, newIOLimiter())
+
+	n, err := w.Write(bytes.Repeat([]byte("x"), 250))
+	if err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want: %v", err, context.DeadlineExceeded)
+	}
+	if n >= 250 {
+		t.Errorf("n = %d, want: < 250", n)
+	}
+}
@@ -0,0 +1,290 @@
+package slidingwindow
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewKeyedWindow creates a new window for the given key, and returns
+// a function to stop the possible sync behaviour within it.
+type NewKeyedWindow func(key string) (Window, StopFunc)
+
+// KeyedBoundary returns the boundary policy of the limiter of key, whose
+// windows are of the given size.
+type KeyedBoundary func(key string, size time.Duration) Boundary
+
+// SpreadBoundary is a KeyedBoundary that shifts the fixed-size windows of
+// each key by KeyOffset, so that the keys do not roll over their windows
+// at the same time.
+func SpreadBoundary(key string, size time.Duration) Boundary {
+	return Offset(Every(size), KeyOffset(key, size))
+}
+
+type keyedEntry struct {
+	lim  *Limiter
+	stop StopFunc
+}
+
+// KeyedLimiter manages a set of limiters, one for each key, which are
+// created lazily with the same size and limit.
+type KeyedLimiter struct {
+	size      time.Duration
+	limit     int64
+	boundary  KeyedBoundary
+	newWindow NewKeyedWindow
+
+	mu         sync.RWMutex
+	limiters   map[string]keyedEntry
+	overrides  map[string]*override
+	evictTimer *time.Timer
+}
+
+// NewKeyedLimiter creates a new keyed limiter, whose limiters will use
+// the windows created by newWindow.
+func NewKeyedLimiter(size time.Duration, limit int64, newWindow NewKeyedWindow) *KeyedLimiter {
+	return NewKeyedBoundaryLimiter(size, limit, nil, newWindow)
+}
+
+// NewKeyedBoundaryLimiter creates a new keyed limiter, whose limiters are
+// aligned by the boundary policies returned by boundary (e.g. SpreadBoundary),
+// and will use the windows created by newWindow. A nil boundary means the
+// fixed-size windows of NewLimiter.
+func NewKeyedBoundaryLimiter(size time.Duration, limit int64, boundary KeyedBoundary, newWindow NewKeyedWindow) *KeyedLimiter {
+	if boundary == nil {
+		boundary = func(key string, size time.Duration) Boundary {
+			return Every(size)
+		}
+	}
+	return &KeyedLimiter{
+		size:      size,
+		limit:     limit,
+		boundary:  boundary,
+		newWindow: newWindow,
+		limiters:  make(map[string]keyedEntry),
+		overrides: make(map[string]*override),
+	}
+}
+
+// Size returns the time duration of one window size.
+func (k *KeyedLimiter) Size() time.Duration {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.size
+}
+
+// SetSize sets a new window size for all the limiters, including the ones
+// created later. The counts of the existing limiters are migrated, see
+// Limiter.SetBoundary.
+func (k *KeyedLimiter) SetSize(newSize time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.size = newSize
+	now := time.Now()
+	for key, e := range k.limiters {
+		e.lim.SetBoundary(now, k.boundary(key, newSize))
+	}
+}
+
+// Limit returns the maximum events permitted to happen during one window
+// size, for each key.
+func (k *KeyedLimiter) Limit() int64 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.limit
+}
+
+// SetLimit sets a new limit for all the limiters, including the ones
+// created later, except for the keys whose limits are overridden.
+func (k *KeyedLimiter) SetLimit(newLimit int64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.limit = newLimit
+	for key, e := range k.limiters {
+		if _, ok := k.overrides[key]; !ok {
+			e.lim.SetLimit(newLimit)
+		}
+	}
+}
+
+// Limiter returns the limiter of key, which will be created if not exists.
+func (k *KeyedLimiter) Limiter(key string) *Limiter {
+	if lim := k.get(key); lim != nil {
+		return lim
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Check again in case the limiter has been created by others.
+	if e, ok := k.limiters[key]; ok {
+		return e.lim
+	}
+
+	limit := k.limit
+	if o, ok := k.overrides[key]; ok {
+		limit = o.limit
+	}
+
+	lim, stop := NewBoundaryLimiter(k.boundary(key, k.size), limit, func() (Window, StopFunc) {
+		return k.newWindow(key)
+	})
+	k.limiters[key] = keyedEntry{lim: lim, stop: stop}
+	return lim
+}
+
+// Lookup returns the limiter of key, without creating it if not exists.
+func (k *KeyedLimiter) Lookup(key string) (*Limiter, bool) {
+	lim := k.get(key)
+	return lim, lim != nil
+}
+
+// get returns the limiter of key, or nil if not exists.
+func (k *KeyedLimiter) get(key string) *Limiter {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.limiters[key].lim
+}
+
+// Keys returns the sorted keys of all the existing limiters.
+func (k *KeyedLimiter) Keys() []string {
+	k.mu.RLock()
+	keys := make([]string, 0, len(k.limiters))
+	for key := range k.limiters {
+		keys = append(keys, key)
+	}
+	k.mu.RUnlock()
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Allow is shorthand for AllowN(key, time.Now(), 1).
+func (k *KeyedLimiter) Allow(key string) bool {
+	return k.AllowN(key, time.Now(), 1)
+}
+
+// AllowN reports whether n events of key may happen at time now.
+func (k *KeyedLimiter) AllowN(key string, now time.Time, n int64) bool {
+	return k.Limiter(key).AllowN(now, n)
+}
+
+// Remove stops and removes the limiter of key, if any.
+func (k *KeyedLimiter) Remove(key string) {
+	k.mu.Lock()
+	e, ok := k.limiters[key]
+	delete(k.limiters, key)
+	k.mu.Unlock()
+
+	if ok {
+		e.stop()
+	}
+}
+
+// Evict stops and removes the limiters that are idle at time now, i.e. whose
+// sliding windows have no events and which have no changes to be synced,
+// and returns the number of the removed limiters. Since a new limiter of
+// the same key starts with the same (zero) count, or gets the count from
+// the central datastore, nothing is lost except for the events of the
+// decisions in flight on the removed limiters.
+func (k *KeyedLimiter) Evict(now time.Time) int {
+	k.mu.RLock()
+	entries := make(map[string]*Limiter, len(k.limiters))
+	for key, e := range k.limiters {
+		entries[key] = e.lim
+	}
+	k.mu.RUnlock()
+
+	var idle []string
+	for key, lim := range entries {
+		if lim.Count(now) == 0 && lim.Pending() == 0 {
+			idle = append(idle, key)
+		}
+	}
+	if len(idle) == 0 {
+		return 0
+	}
+
+	var stops []StopFunc
+	k.mu.Lock()
+	for _, key := range idle {
+		// Skip the limiters that have been replaced in the meantime.
+		if e, ok := k.limiters[key]; ok && e.lim == entries[key] {
+			delete(k.limiters, key)
+			stops = append(stops, e.stop)
+		}
+	}
+	k.mu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+	return len(stops)
+}
+
+// SetEvictInterval makes k evict the idle limiters (see Evict) every
+// interval, until Stop is called. This is necessary if the keys are
+// unbounded (e.g. client addresses), since the limiters are never removed
+// otherwise. A non-positive interval disables the eviction.
+func (k *KeyedLimiter) SetEvictInterval(interval time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.evictTimer != nil {
+		k.evictTimer.Stop()
+		k.evictTimer = nil
+	}
+	if interval <= 0 {
+		return
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(interval, func() {
+		k.Evict(time.Now())
+
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		// Do nothing if the eviction has been changed or stopped.
+		if k.evictTimer == t {
+			t.Reset(interval)
+		}
+	})
+	k.evictTimer = t
+}
+
+// Flush syncs the pending changes of all the limiters, see Limiter.Flush.
+func (k *KeyedLimiter) Flush() {
+	k.mu.RLock()
+	limiters := make([]*Limiter, 0, len(k.limiters))
+	for _, e := range k.limiters {
+		limiters = append(limiters, e.lim)
+	}
+	k.mu.RUnlock()
+
+	for _, lim := range limiters {
+		lim.Flush()
+	}
+}
+
+// Stop stops and removes all the limiters, as well as all the overrides
+// and the eviction.
+func (k *KeyedLimiter) Stop() {
+	k.mu.Lock()
+	if k.evictTimer != nil {
+		k.evictTimer.Stop()
+		k.evictTimer = nil
+	}
+	limiters := k.limiters
+	k.limiters = make(map[string]keyedEntry)
+	for _, o := range k.overrides {
+		o.timer.Stop()
+	}
+	k.overrides = make(map[string]*override)
+	k.mu.Unlock()
+
+	for _, e := range limiters {
+		e.stop()
+	}
+}
@@ -0,0 +1,122 @@
+package slidingwindow
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiter_AllowN(t *testing.T) {
+	var stopped []string
+	k := NewKeyedLimiter(size, limit, func(key string) (Window, StopFunc) {
+		w, _ := NewLocalWindow()
+		return w, func() { stopped = append(stopped, key) }
+	})
+
+	cases := []struct {
+		key string
+		caseArg
+	}{
+		{"a", caseArg{t0, 10, true}},
+		{"a", caseArg{t1, 1, false}},
+		{"b", caseArg{t1, 10, true}}, // keys are limited independently
+		{"b", caseArg{t2, 1, false}},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := k.AllowN(c.key, c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("k.AllowN(%q, %v, %v) = %v, want: %v",
+					c.key, c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+
+	if keys := k.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("k.Keys() = %v, want: [a b]", keys)
+	}
+
+	k.Remove("a")
+	k.Stop()
+	if !reflect.DeepEqual(stopped, []string{"a", "b"}) {
+		t.Errorf("stopped = %v, want: [a b]", stopped)
+	}
+	if keys := k.Keys(); len(keys) != 0 {
+		t.Errorf("k.Keys() = %v, want: []", keys)
+	}
+}
+
+func TestKeyedLimiter_SetLimit_SetSize(t *testing.T) {
+	k := NewKeyedLimiter(size, limit, func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+
+	if ok := k.AllowN("a", t0, limit); !ok {
+		t.Fatalf("k.AllowN(%q, %v, %v) = false, want: true", "a", t0, limit)
+	}
+
+	k.SetLimit(2 * limit)
+	if ok := k.AllowN("a", t1, limit); !ok {
+		t.Errorf("k.AllowN(%q, %v, %v) = false, want: true", "a", t1, limit)
+	}
+	if got := k.Limiter("b").Limit(); got != 2*limit {
+		t.Errorf("k.Limiter(%q).Limit() = %d, want: %d", "b", got, 2*limit)
+	}
+
+	k.SetSize(2 * size)
+	for _, key := range []string{"a", "b", "c"} {
+		if got := k.Limiter(key).Size(); got != 2*size {
+			t.Errorf("k.Limiter(%q).Size() = %v, want: %v", key, got, 2*size)
+		}
+	}
+}
+
+func TestKeyedLimiter_SpreadBoundary(t *testing.T) {
+	k := NewKeyedBoundaryLimiter(size, limit, SpreadBoundary, func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+
+	for _, key := range []string{"a", "b"} {
+		lim := k.Limiter(key)
+		lim.AllowN(t0, 1)
+		want := Offset(Every(size), KeyOffset(key, size)).Truncate(t0)
+		if got := lim.curr.Start(); !got.Equal(want) {
+			t.Errorf("%q: got start %v, want: %v", key, got, want)
+		}
+	}
+
+	k.SetSize(2 * size)
+	want := Offset(Every(2*size), KeyOffset("a", 2*size)).Truncate(t0)
+	if got := k.Limiter("a").boundary.Truncate(t0); !got.Equal(want) {
+		t.Errorf("after SetSize: got start %v, want: %v", got, want)
+	}
+}
+
+func TestKeyedLimiter_Evict(t *testing.T) {
+	k := NewKeyedLimiter(size, limit, func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+	defer k.Stop()
+
+	k.AllowN("a", t0, 5)
+	k.AllowN("b", t10, 1)
+
+	// The events of both keys are still within the sliding window.
+	if n := k.Evict(t12); n != 0 {
+		t.Errorf("k.Evict(t12) = %d, want: 0", n)
+	}
+	if n := k.Evict(t30); n != 2 {
+		t.Errorf("k.Evict(t30) = %d, want: 2", n)
+	}
+	if keys := k.Keys(); len(keys) != 0 {
+		t.Errorf("k.Keys() = %v, want: []", keys)
+	}
+
+	k.Allow("c")
+	k.SetEvictInterval(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if keys := k.Keys(); !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Errorf("k.Keys() = %v, want: [c]", keys)
+	}
+}
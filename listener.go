@@ -0,0 +1,209 @@
+package slidingwindow
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// errListenerClosed is returned by Accept after the listener is closed.
+var errListenerClosed = errors.New("slidingwindow: listener closed")
+
+// ListenerConfig holds the settings of Listener.
+type ListenerConfig struct {
+	// The prefix lengths that the source addresses are aggregated by, e.g.
+	// 24 for IPv4 (/24) and 64 for IPv6 (/64). Zero means no aggregation.
+	IPv4Prefix int
+	IPv6Prefix int
+
+	// The maximum time that an excess connection will be delayed for,
+	// until it is permitted. Zero means closing excess connections
+	// immediately.
+	Delay time.Duration
+
+	// The maximum number of connections being delayed at once, beyond
+	// which excess connections are closed immediately. Zero means 1024.
+	MaxDelayed int
+}
+
+// defaultMaxDelayed is the default value of ListenerConfig.MaxDelayed.
+const defaultMaxDelayed = 1024
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// Listener is a net.Listener that limits the rate of accepted connections
+// per source IP (or subnet), by using a keyed limiter.
+type Listener struct {
+	net.Listener
+
+	lim    *KeyedLimiter
+	config ListenerConfig
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	acceptC   chan acceptResult
+	closeC    chan struct{}
+	delayedC  chan struct{} // The semaphore of the delayed connections.
+
+	// ctx is done once the listener is closed, which stops the waits of
+	// the delayed connections.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewListener returns a listener that accepts connections from l, at the
+// rate limited by lim, keyed by the (aggregated) source addresses.
+//
+// Since the source addresses are up to the clients, lim should evict the
+// idle keys, see KeyedLimiter.SetEvictInterval.
+func NewListener(l net.Listener, lim *KeyedLimiter, config ListenerConfig) *Listener {
+	if config.MaxDelayed <= 0 {
+		config.MaxDelayed = defaultMaxDelayed
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Listener{
+		Listener: l,
+		lim:      lim,
+		config:   config,
+		acceptC:  make(chan acceptResult),
+		closeC:   make(chan struct{}),
+		delayedC: make(chan struct{}, config.MaxDelayed),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Accept waits for and returns the next permitted connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	l.startOnce.Do(func() {
+		go l.acceptLoop()
+	})
+
+	select {
+	case r := <-l.acceptC:
+		return r.conn, r.err
+	case <-l.closeC:
+		return nil, errListenerClosed
+	}
+}
+
+// Close closes the underlying listener, and all the connections that are
+// still being delayed.
+func (l *Listener) Close() error {
+	err := l.Listener.Close()
+	l.startOnce.Do(func() {}) // Prevent acceptLoop from being started.
+	l.closeOnce.Do(func() {
+		close(l.closeC)
+		l.cancel()
+	})
+	return err
+}
+
+// acceptLoop accepts connections from the underlying listener, and passes
+// the permitted ones to Accept.
+func (l *Listener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() { // nolint:staticcheck
+				l.deliver(acceptResult{err: err})
+				continue
+			}
+			// Keep reporting the permanent error until the listener is closed.
+			for l.deliver(acceptResult{err: err}) {
+			}
+			return
+		}
+
+		key := l.Key(conn.RemoteAddr())
+		switch {
+		case l.lim.Allow(key):
+			l.deliver(acceptResult{conn: conn})
+		case l.config.Delay > 0 && l.acquireDelay():
+			go l.delay(key, conn)
+		default:
+			conn.Close() // nolint:errcheck
+		}
+	}
+}
+
+// acquireDelay reports whether another connection may be delayed, which
+// must be followed by a call to releaseDelay if so.
+func (l *Listener) acquireDelay() bool {
+	select {
+	case l.delayedC <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Listener) releaseDelay() {
+	<-l.delayedC
+}
+
+// delay waits until the connection is permitted, or closes it if the wait
+// exceeds the configured delay, or the listener is closed.
+func (l *Listener) delay(key string, conn net.Conn) {
+	defer l.releaseDelay()
+
+	ctx, cancel := context.WithTimeout(l.ctx, l.config.Delay)
+	defer cancel()
+
+	if err := l.lim.Limiter(key).Wait(ctx); err != nil {
+		conn.Close() // nolint:errcheck
+		return
+	}
+	l.deliver(acceptResult{conn: conn})
+}
+
+// deliver passes r to Accept, unless the listener has been closed.
+// It reports whether r has been passed.
+func (l *Listener) deliver(r acceptResult) bool {
+	select {
+	case l.acceptC <- r:
+		return true
+	case <-l.closeC:
+		if r.conn != nil {
+			r.conn.Close() // nolint:errcheck
+		}
+		return false
+	}
+}
+
+// Key returns the key of the source address addr, which is the IP masked
+// by the configured prefix length, in CIDR notation.
+func (l *Listener) Key(addr net.Addr) string {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return addr.String()
+		}
+		ip = net.ParseIP(host)
+		if ip == nil {
+			return host
+		}
+	}
+
+	bits, prefix := 128, l.config.IPv6Prefix
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits, prefix = ip4, 32, l.config.IPv4Prefix
+	}
+	if prefix <= 0 || prefix > bits {
+		prefix = bits
+	}
+
+	ipNet := net.IPNet{IP: ip.Mask(net.CIDRMask(prefix, bits)), Mask: net.CIDRMask(prefix, bits)}
+	return ipNet.String()
+}
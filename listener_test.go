@@ -0,0 +1,161 @@
+package slidingwindow
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newListenerLimiter(size time.Duration, limit int64) *KeyedLimiter {
+	return NewKeyedLimiter(size, limit, func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+}
+
+// dial connects to l, and reports whether the connection is accepted,
+// i.e. it is not closed by the listener within timeout.
+func dial(t *testing.T, l net.Listener, timeout time.Duration) bool {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(timeout)) // nolint:errcheck
+	_, err = conn.Read(make([]byte, 1))
+	if err == io.EOF {
+		return false
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return true
+	}
+	t.Fatalf("err: %v", err)
+	return false
+}
+
+// serve accepts connections from l, and keeps them open until l is closed.
+func serve(l net.Listener) {
+	var conns []net.Conn
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conns = append(conns, conn)
+	}
+}
+
+func TestListener_Close(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l := NewListener(ln, newListenerLimiter(time.Minute, 2), ListenerConfig{})
+	go serve(l)
+	defer l.Close()
+
+	cases := []bool{true, true, false}
+	for _, want := range cases {
+		if got := dial(t, l, 100*time.Millisecond); got != want {
+			t.Errorf("accepted = %v, want: %v", got, want)
+		}
+	}
+}
+
+func TestListener_Delay(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l := NewListener(ln, newListenerLimiter(d, 1), ListenerConfig{Delay: 5 * d})
+	go serve(l)
+	defer l.Close()
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		// The second connection is delayed, but not closed.
+		if !dial(t, l, 300*time.Millisecond) {
+			t.Errorf("accepted = false, want: true")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < d {
+		t.Errorf("elapsed = %v, want: >= %v", elapsed, d)
+	}
+}
+
+func TestListener_MaxDelayed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l := NewListener(ln, newListenerLimiter(time.Minute, 1), ListenerConfig{Delay: 5 * d, MaxDelayed: 1})
+	go serve(l)
+	defer l.Close()
+
+	// The second connection is still being delayed when the third one
+	// comes, which is closed since no more connections can be delayed.
+	cases := []bool{true, true, false}
+	for _, want := range cases {
+		if got := dial(t, l, 100*time.Millisecond); got != want {
+			t.Errorf("accepted = %v, want: %v", got, want)
+		}
+	}
+}
+
+func TestListener_Close_Delayed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l := NewListener(ln, newListenerLimiter(time.Minute, 1), ListenerConfig{Delay: time.Minute})
+	go serve(l)
+
+	if !dial(t, l, 100*time.Millisecond) {
+		t.Errorf("accepted = false, want: true")
+	}
+
+	// The second connection is being delayed when the listener is closed,
+	// which closes it long before the delay ends.
+	time.AfterFunc(100*time.Millisecond, func() { l.Close() })
+	if dial(t, l, 5*time.Second) {
+		t.Errorf("accepted = true, want: false")
+	}
+}
+
+func TestListener_Key(t *testing.T) {
+	l := NewListener(nil, nil, ListenerConfig{IPv4Prefix: 24, IPv6Prefix: 64})
+
+	cases := []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 80}, "192.168.1.0/24"},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.20"), Port: 80}, "192.168.1.0/24"},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.2.10"), Port: 80}, "192.168.2.0/24"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8:0:1:1::1"), Port: 80}, "2001:db8:0:1::/64"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8:0:2::1"), Port: 80}, "2001:db8:0:2::/64"},
+	}
+
+	for _, c := range cases {
+		if got := l.Key(c.addr); got != c.want {
+			t.Errorf("l.Key(%v) = %q, want: %q", c.addr, got, c.want)
+		}
+	}
+
+	l = NewListener(nil, nil, ListenerConfig{})
+	addr := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 80}
+	if got := l.Key(addr); got != "192.168.1.10/32" {
+		t.Errorf("l.Key(%v) = %q, want: %q", addr, got, "192.168.1.10/32")
+	}
+}
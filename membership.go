@@ -0,0 +1,156 @@
+package slidingwindow
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Membership derives the number of live nodes in the cluster, by having
+// each node publish heartbeats through the central datastore, so that
+// limiters can enforce their shares of the limit locally, without syncing
+// their counts.
+//
+// Every node adds 1 to the count of the heartbeat window (of the given
+// interval) that the current time falls into, and the number of live nodes
+// is the count of the latest complete window, or of the current one if
+// it is larger (i.e. the cluster is scaling out).
+type Membership struct {
+	key      string
+	store    Datastore
+	interval time.Duration
+
+	nodes    int64 // accessed atomically
+	lastBeat int64 // The start of the window of the last heartbeat.
+
+	mu sync.Mutex
+	// The limiters following the membership, and their limits of the
+	// whole cluster.
+	limiters map[*Limiter]int64
+
+	stopC chan struct{}
+	exitC chan struct{}
+}
+
+// NewMembership creates a new membership, whose heartbeats are published
+// to store under key for every interval.
+func NewMembership(key string, store Datastore, interval time.Duration) *Membership {
+	return &Membership{
+		key:      key,
+		store:    store,
+		interval: interval,
+		nodes:    1,
+		limiters: make(map[*Limiter]int64),
+		stopC:    make(chan struct{}),
+		exitC:    make(chan struct{}),
+	}
+}
+
+// Start starts the heartbeat goroutine.
+func (m *Membership) Start() {
+	m.beat(time.Now())
+	go m.beatLoop()
+}
+
+// Stop stops the heartbeat goroutine, and waits for it to exit.
+func (m *Membership) Stop() {
+	close(m.stopC)
+	<-m.exitC
+}
+
+// Nodes returns the number of live nodes, which is at least 1.
+func (m *Membership) Nodes() int64 {
+	return atomic.LoadInt64(&m.nodes)
+}
+
+// Share returns the share of limit for the current node, which is rounded
+// down so that the total of the cluster never exceeds limit. However, the
+// share is at least 1 (if limit is positive), so that every node can make
+// progress, in which case the total may exceed limit if there are more
+// nodes than limit.
+func (m *Membership) Share(limit int64) int64 {
+	share := limit / m.Nodes()
+	if share < 1 && limit > 0 {
+		share = 1
+	}
+	return share
+}
+
+// NewLimiter creates a new limiter, whose limit is kept at the share of
+// limit for the current node, as the number of live nodes changes. It also
+// returns a function to stop the limiter from following the membership.
+func (m *Membership) NewLimiter(size time.Duration, limit int64, newWindow NewWindow) (*Limiter, StopFunc) {
+	lim, stop := NewLimiter(size, m.Share(limit), newWindow)
+
+	m.mu.Lock()
+	m.limiters[lim] = limit
+	m.mu.Unlock()
+
+	return lim, func() {
+		m.mu.Lock()
+		delete(m.limiters, lim)
+		m.mu.Unlock()
+
+		stop()
+	}
+}
+
+// beatLoop publishes heartbeats periodically. The ticks are twice as
+// frequent as the interval, to ensure that no heartbeat window is missed.
+func (m *Membership) beatLoop() {
+	ticker := time.NewTicker(m.interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			m.beat(now)
+		case <-m.stopC:
+			close(m.exitC)
+			return
+		}
+	}
+}
+
+// beat publishes the heartbeat at time now if it has not been published
+// within the current heartbeat window, and updates the number of live nodes.
+func (m *Membership) beat(now time.Time) {
+	start := now.Truncate(m.interval).UnixNano()
+
+	var curr int64
+	var err error
+	if start != m.lastBeat {
+		curr, err = m.store.Add(m.key, start, 1)
+		if err == nil {
+			m.lastBeat = start
+		}
+	} else {
+		curr, err = m.store.Get(m.key, start)
+	}
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		return
+	}
+
+	prev, err := m.store.Get(m.key, start-int64(m.interval))
+	if err != nil {
+		log.Printf("err: %v\n", err)
+		return
+	}
+
+	nodes := prev
+	if curr > nodes {
+		nodes = curr
+	}
+	if nodes < 1 {
+		nodes = 1
+	}
+	atomic.StoreInt64(&m.nodes, nodes)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for lim, limit := range m.limiters {
+		lim.SetLimit(m.Share(limit))
+	}
+}
@@ -0,0 +1,77 @@
+package slidingwindow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMembership(t *testing.T) {
+	store := newMemDatastore()
+	interval := time.Second
+	m1 := NewMembership("nodes", store, interval)
+	m2 := NewMembership("nodes", store, interval)
+
+	lim, stop := m1.NewLimiter(size, limit, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+	defer stop()
+
+	cases := []struct {
+		beats     []*Membership
+		t         time.Time
+		wantNodes int64
+		wantLimit int64
+	}{
+		{[]*Membership{m1}, t0, 1, 10},
+		{[]*Membership{m2, m1}, t5, 2, 5}, // m2 joins
+		{[]*Membership{m1, m2}, t10, 2, 5},
+		{[]*Membership{m1}, t15, 2, 5},
+		{[]*Membership{m1}, t30, 1, 10}, // m2 leaves
+	}
+
+	for _, c := range cases {
+		for _, m := range c.beats {
+			m.beat(c.t)
+		}
+
+		if nodes := m1.Nodes(); nodes != c.wantNodes {
+			t.Errorf("m1.Nodes() at %v = %d, want: %d", c.t, nodes, c.wantNodes)
+		}
+		if limit := lim.Limit(); limit != c.wantLimit {
+			t.Errorf("lim.Limit() at %v = %d, want: %d", c.t, limit, c.wantLimit)
+		}
+	}
+}
+
+func TestMembership_Share(t *testing.T) {
+	m := NewMembership("nodes", newMemDatastore(), time.Second)
+
+	cases := []struct {
+		nodes int64
+		limit int64
+		want  int64
+	}{
+		{1, 10, 10},
+		{3, 10, 3}, // rounded down, so the total is 9 rather than 12
+		{20, 10, 1},
+		{20, 0, 0},
+	}
+
+	for _, c := range cases {
+		m.nodes = c.nodes
+		if got := m.Share(c.limit); got != c.want {
+			t.Errorf("Share(%d) with %d nodes = %d, want: %d", c.limit, c.nodes, got, c.want)
+		}
+	}
+}
+
+func TestMembership_StartStop(t *testing.T) {
+	m := NewMembership("nodes", newMemDatastore(), 10*time.Millisecond)
+	m.Start()
+	time.Sleep(30 * time.Millisecond)
+	m.Stop()
+
+	if nodes := m.Nodes(); nodes != 1 {
+		t.Errorf("m.Nodes() = %d, want: 1", nodes)
+	}
+}
@@ -0,0 +1,86 @@
+package slidingwindow
+
+import (
+	"sort"
+	"time"
+)
+
+// override is a temporary limit of a key.
+type override struct {
+	limit int64
+	until time.Time
+	timer *time.Timer
+}
+
+// Override describes the temporary limit of a key, see KeyedLimiter.Override.
+type Override struct {
+	Key   string    `json:"key"`
+	Limit int64     `json:"limit"`
+	Until time.Time `json:"until"`
+}
+
+// Override overrides the limit of key with limit for the duration d, after
+// which the limit of key falls back to the one of k.
+//
+// The override takes effect on the limiter of key, whether it exists now
+// or is created later (e.g. after Remove), and takes precedence over
+// SetLimit. Overriding the same key again replaces the previous override.
+func (k *KeyedLimiter) Override(key string, limit int64, d time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.clearOverride(key)
+
+	o := &override{limit: limit, until: time.Now().Add(d)}
+	o.timer = time.AfterFunc(d, func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		// Do nothing if the override has been replaced or cleared.
+		if k.overrides[key] == o {
+			k.clearOverride(key)
+		}
+	})
+	k.overrides[key] = o
+
+	if e, ok := k.limiters[key]; ok {
+		e.lim.SetLimit(limit)
+	}
+}
+
+// ClearOverride removes the override of key, if any.
+func (k *KeyedLimiter) ClearOverride(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.clearOverride(key)
+}
+
+// clearOverride removes the override of key, and restores the limit of
+// the limiter of key, if any.
+//
+// Note that clearOverride must be called with mu held.
+func (k *KeyedLimiter) clearOverride(key string) {
+	o, ok := k.overrides[key]
+	if !ok {
+		return
+	}
+	o.timer.Stop()
+	delete(k.overrides, key)
+
+	if e, ok := k.limiters[key]; ok {
+		e.lim.SetLimit(k.limit)
+	}
+}
+
+// Overrides returns all the overrides in effect, sorted by key.
+func (k *KeyedLimiter) Overrides() []Override {
+	k.mu.RLock()
+	overrides := make([]Override, 0, len(k.overrides))
+	for key, o := range k.overrides {
+		overrides = append(overrides, Override{Key: key, Limit: o.limit, Until: o.until})
+	}
+	k.mu.RUnlock()
+
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Key < overrides[j].Key })
+	return overrides
+}
@@ -0,0 +1,54 @@
+package slidingwindow
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiter_Override(t *testing.T) {
+	k := NewKeyedLimiter(size, limit, func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+	defer k.Stop()
+
+	limits := func(keys ...string) (got []int64) {
+		for _, key := range keys {
+			got = append(got, k.Limiter(key).Limit())
+		}
+		return got
+	}
+
+	k.Limiter("a")
+	k.Override("a", 1, time.Hour)
+	k.Override("b", 2, time.Hour) // b is created later
+	k.Override("c", 3, 20*time.Millisecond)
+	if got := limits("a", "b", "c", "d"); !reflect.DeepEqual(got, []int64{1, 2, 3, limit}) {
+		t.Errorf("limits = %v, want: [1 2 3 %d]", got, limit)
+	}
+
+	var keys []string
+	for _, o := range k.Overrides() {
+		keys = append(keys, o.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Errorf("overridden keys = %v, want: [a b c]", keys)
+	}
+
+	// The overridden keys are not affected by SetLimit.
+	k.SetLimit(20)
+	if got := limits("a", "b", "c", "d"); !reflect.DeepEqual(got, []int64{1, 2, 3, 20}) {
+		t.Errorf("limits = %v, want: [1 2 3 20]", got)
+	}
+
+	// The override of a also survives the removal of its limiter.
+	k.Remove("a")
+	k.ClearOverride("b")
+	time.Sleep(50 * time.Millisecond) // The override of c expires.
+	if got := limits("a", "b", "c", "d"); !reflect.DeepEqual(got, []int64{1, 20, 20, 20}) {
+		t.Errorf("limits = %v, want: [1 20 20 20]", got)
+	}
+	if n := len(k.Overrides()); n != 1 {
+		t.Errorf("len(k.Overrides()) = %d, want: 1", n)
+	}
+}
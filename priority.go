@@ -0,0 +1,114 @@
+package slidingwindow
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Priority represents the priority class of events.
+type Priority int
+
+// The priority classes, from the lowest to the highest.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	numPriorities
+)
+
+// priorityClass holds the settings and the counters of one priority class.
+type priorityClass struct {
+	reserved uint64 // the bits of a float64, accessed atomically
+
+	allowed int64 // accessed atomically
+	denied  int64 // accessed atomically
+}
+
+// PriorityStats holds the counters of events of one priority class.
+type PriorityStats struct {
+	Allowed int64
+	Denied  int64
+}
+
+// class returns the priority class of p, which is clamped into the range
+// between PriorityLow and PriorityHigh.
+func (lim *Limiter) class(p Priority) *priorityClass {
+	if p < PriorityLow {
+		p = PriorityLow
+	}
+	if p > PriorityHigh {
+		p = PriorityHigh
+	}
+	return &lim.priorities[p]
+}
+
+// SetReserved reserves the given fraction (e.g. 0.2) of the limit from the
+// events of priority p, which will be denied once the count reaches the rest
+// of the limit, so that the reserved capacity is left for higher priorities.
+//
+// It returns an error, without changing anything, if fraction is not
+// between 0 and 1, or if the fractions reserved from all the priorities
+// would sum up to more than 1.
+func (lim *Limiter) SetReserved(p Priority, fraction float64) error {
+	if !(fraction >= 0 && fraction <= 1) {
+		return fmt.Errorf("slidingwindow: reserved fraction %v is not between 0 and 1", fraction)
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	c := lim.class(p)
+	sum := fraction
+	for i := range lim.priorities {
+		if other := &lim.priorities[i]; other != c {
+			sum += math.Float64frombits(atomic.LoadUint64(&other.reserved))
+		}
+	}
+	if sum > 1 {
+		return fmt.Errorf("slidingwindow: reserved fractions sum up to %v, more than 1", sum)
+	}
+
+	atomic.StoreUint64(&c.reserved, math.Float64bits(fraction))
+	return nil
+}
+
+// Reserved returns the fraction of the limit reserved from priority p.
+func (lim *Limiter) Reserved(p Priority) float64 {
+	return math.Float64frombits(atomic.LoadUint64(&lim.class(p).reserved))
+}
+
+// AllowPriority is shorthand for AllowPriorityN(time.Now(), 1, p).
+func (lim *Limiter) AllowPriority(p Priority) bool {
+	return lim.AllowPriorityN(time.Now(), 1, p)
+}
+
+// AllowPriorityN reports whether n events of priority p may happen at
+// time now. The decision is the same as AllowN, except that the limit is
+// reduced by the fraction reserved from p.
+func (lim *Limiter) AllowPriorityN(now time.Time, n int64, p Priority) bool {
+	c := lim.class(p)
+
+	limit := lim.Limit()
+	limit -= int64(math.Ceil(float64(limit) * lim.Reserved(p)))
+
+	ok := lim.allowN(now, n, limit)
+	if ok {
+		atomic.AddInt64(&c.allowed, n)
+	} else {
+		atomic.AddInt64(&c.denied, n)
+	}
+	return ok
+}
+
+// PriorityStats returns the counters of events of priority p, which have
+// been checked by AllowPriorityN.
+func (lim *Limiter) PriorityStats(p Priority) PriorityStats {
+	c := lim.class(p)
+	return PriorityStats{
+		Allowed: atomic.LoadInt64(&c.allowed),
+		Denied:  atomic.LoadInt64(&c.denied),
+	}
+}
@@ -0,0 +1,87 @@
+package slidingwindow
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLimiter_AllowPriorityN(t *testing.T) {
+	lim, _ := NewLimiter(size, limit, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+	if err := lim.SetReserved(PriorityLow, 0.2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := lim.SetReserved(PriorityNormal, 0.1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		p Priority
+		caseArg
+	}{
+		{PriorityLow, caseArg{t0, 5, true}},
+		{PriorityLow, caseArg{t1, 3, true}},
+		{PriorityLow, caseArg{t1, 1, false}}, // the last 20% is reserved
+		{PriorityNormal, caseArg{t1, 1, true}},
+		{PriorityNormal, caseArg{t2, 1, false}}, // the last 10% is reserved
+		{PriorityHigh, caseArg{t2, 1, true}},
+		{PriorityHigh, caseArg{t2, 1, false}},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := lim.AllowPriorityN(c.t, c.n, c.p)
+			if ok != c.ok {
+				t.Errorf("lim.AllowPriorityN(%v, %v, %v) = %v, want: %v",
+					c.t, c.n, c.p, ok, c.ok)
+			}
+		})
+	}
+
+	for _, c := range []struct {
+		p    Priority
+		want PriorityStats
+	}{
+		{PriorityLow, PriorityStats{Allowed: 8, Denied: 1}},
+		{PriorityNormal, PriorityStats{Allowed: 1, Denied: 1}},
+		{PriorityHigh, PriorityStats{Allowed: 1, Denied: 1}},
+	} {
+		if got := lim.PriorityStats(c.p); got != c.want {
+			t.Errorf("lim.PriorityStats(%v) = %+v, want: %+v", c.p, got, c.want)
+		}
+	}
+}
+
+func TestLimiter_SetReserved(t *testing.T) {
+	lim, _ := NewLimiter(size, limit, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+
+	cases := []struct {
+		p        Priority
+		fraction float64
+		ok       bool
+	}{
+		{PriorityLow, -0.1, false},
+		{PriorityLow, 1.1, false},
+		{PriorityLow, math.NaN(), false},
+		{PriorityLow, 0.6, true},
+		{PriorityNormal, 0.5, false}, // 0.6 + 0.5 > 1
+		{PriorityNormal, 0.4, true},
+		{PriorityLow, 0.5, true}, // replaces 0.6
+		{PriorityHigh, 0.2, false},
+	}
+	for _, c := range cases {
+		err := lim.SetReserved(c.p, c.fraction)
+		if (err == nil) != c.ok {
+			t.Errorf("lim.SetReserved(%v, %v) err = %v, want ok: %v", c.p, c.fraction, err, c.ok)
+		}
+	}
+
+	for p, want := range map[Priority]float64{PriorityLow: 0.5, PriorityNormal: 0.4, PriorityHigh: 0} {
+		if got := lim.Reserved(p); got != want {
+			t.Errorf("lim.Reserved(%v) = %v, want: %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/RussellLuo/slidingwindow/redisstore
+
+go 1.13
+
+require (
+	github.com/RussellLuo/slidingwindow v0.0.0-00010101000000-000000000000
+	github.com/go-redis/redis v6.15.9+incompatible
+)
+
+replace github.com/RussellLuo/slidingwindow => ../
@@ -0,0 +1,169 @@
+// Package redisstore implements the Datastore on Redis, by using go-redis.
+//
+// The count of each window is kept in the Redis key "key@start", where
+// start is the start boundary of the window in Unix nanoseconds.
+package redisstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/go-redis/redis"
+)
+
+// RedisDatastore is the Redis-based datastore.
+type RedisDatastore struct {
+	client redis.Cmdable
+	ttl    int64 // time.Duration
+}
+
+// NewRedisDatastore creates a new datastore on client, whose keys expire
+// after ttl since the latest Add. Twice of the window size is just enough.
+func NewRedisDatastore(client redis.Cmdable, ttl time.Duration) *RedisDatastore {
+	return &RedisDatastore{client: client, ttl: int64(ttl)}
+}
+
+// TTL returns the time after which the keys expire since the latest Add.
+func (d *RedisDatastore) TTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&d.ttl))
+}
+
+// SetTTL sets a new TTL for the keys, which takes effect on their next Add,
+// e.g. after the window size of the limiters has changed.
+func (d *RedisDatastore) SetTTL(ttl time.Duration) {
+	atomic.StoreInt64(&d.ttl, int64(ttl))
+}
+
+func (d *RedisDatastore) fullKey(key string, start int64) string {
+	return fmt.Sprintf("%s@%d", key, start)
+}
+
+func (d *RedisDatastore) Add(key string, start, value int64) (int64, error) {
+	k := d.fullKey(key, start)
+	c, err := d.client.IncrBy(k, value).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Ignore the possible error from EXPIRE command.
+	d.client.Expire(k, d.TTL()).Result() // nolint:errcheck
+	return c, err
+}
+
+func (d *RedisDatastore) Get(key string, start int64) (int64, error) {
+	k := d.fullKey(key, start)
+	value, err := d.client.Get(k).Result()
+	if err != nil {
+		if err == redis.Nil {
+			// redis.Nil is not an error, it only indicates the key does not exist.
+			err = nil
+		}
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// casScript sets KEYS[1] to ARGV[2] only if its current value (zero if it
+// does not exist) is ARGV[1], and then sets its TTL to ARGV[3] milliseconds
+// if positive.
+var casScript = redis.NewScript(`
+local cur = tonumber(redis.call("GET", KEYS[1]) or "0")
+if cur ~= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// CompareAndSet sets the count of the window represented by start to new,
+// only if the current count is old, by running a Lua script atomically on
+// the server. It makes RedisDatastore a CASDatastore, e.g. for SyncTAT.
+func (d *RedisDatastore) CompareAndSet(key string, start, old, new int64) (bool, error) {
+	k := d.fullKey(key, start)
+	ttl := d.TTL().Milliseconds()
+	n, err := casScript.Run(d.client, []string{k}, old, new, ttl).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// List returns the windows whose keys have the given prefix, sorted by key
+// and then by start. The keyspace is walked by SCAN, so List is safe to use
+// on a live server, while it may miss the keys added in the meantime. The
+// Redis keys not in the "key@start" layout are skipped.
+func (d *RedisDatastore) List(prefix string) ([]sw.WindowCount, error) {
+	pattern := globEscaper.Replace(prefix) + "*@*"
+
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := d.client.Scan(cursor, pattern, 1000).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	var counts []sw.WindowCount
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > 1000 {
+			n = 1000
+		}
+		batch := keys[:n]
+		keys = keys[n:]
+
+		values, err := d.client.MGet(batch...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			key, start, ok := splitFullKey(batch[i])
+			s, isString := v.(string)
+			if !ok || !isString {
+				continue // Not in our layout, or deleted in the meantime.
+			}
+			count, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				continue
+			}
+			counts = append(counts, sw.WindowCount{Key: key, Start: start, Count: count})
+		}
+	}
+
+	sw.SortWindowCounts(counts)
+	return counts, nil
+}
+
+// Delete deletes the window represented by start.
+func (d *RedisDatastore) Delete(key string, start int64) error {
+	return d.client.Del(d.fullKey(key, start)).Err()
+}
+
+// globEscaper escapes the special characters of the glob-style patterns
+// accepted by SCAN.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// splitFullKey splits a Redis key into the key and the window start. Since
+// the key itself may contain "@", the last one is the separator.
+func splitFullKey(full string) (key string, start int64, ok bool) {
+	i := strings.LastIndexByte(full, '@')
+	if i < 0 {
+		return "", 0, false
+	}
+	start, err := strconv.ParseInt(full[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return full[:i], start, true
+}
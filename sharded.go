@@ -0,0 +1,199 @@
+package slidingwindow
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shard is a counter padded to occupy a whole cache line, to avoid
+// false sharing between adjacent shards.
+type shard struct {
+	n int64
+	_ [56]byte
+}
+
+// ShardedWindow represents a window that spreads its count over multiple
+// shards, which is suitable for extremely hot keys, since concurrent callers
+// of AddCount will mostly increment different shards.
+//
+// The count is aggregated on Count, and also on sync if the window is created
+// by NewShardedSyncWindow. Note that the decision made with a ShardedWindow
+// is not atomic: concurrent callers may all pass the check before any of them
+// increments its shard, so the limit may be slightly exceeded.
+type ShardedWindow struct {
+	// The start boundary (timestamp in nanoseconds) of the window.
+	// [start, start + size)
+	start int64
+
+	// The count that is not added by this window itself, i.e. the count
+	// given by Reset, plus the changes accumulated by other limiters.
+	base int64
+
+	// The count added by this window itself.
+	shards []shard
+	// The pool of shard indexes, which is leveraged to pick the shard
+	// belonging to the current P (most of the time).
+	indexes sync.Pool
+	next    uint32
+
+	key    string
+	syncer Synchronizer
+
+	// mu makes Reset and the handling of sync responses mutually exclusive,
+	// so that a response of the OLD window is never applied to a new one.
+	mu sync.Mutex
+
+	syncing int32 // Whether the synchronization is in progress.
+	synced  int64 // The part of the count in shards that has been synced.
+}
+
+// NewShardedWindow creates an instance of ShardedWindow with n shards, or
+// with runtime.GOMAXPROCS(0) shards if n is not positive.
+func NewShardedWindow(n int) (*ShardedWindow, StopFunc) {
+	return newShardedWindow(n), func() {}
+}
+
+// NewShardedSyncWindow creates an instance of ShardedWindow with n shards,
+// which will sync counter data to the central datastore with the given
+// synchronizer, just as SyncWindow does.
+func NewShardedSyncWindow(key string, syncer Synchronizer, n int) (*ShardedWindow, StopFunc) {
+	w := newShardedWindow(n)
+	w.key = key
+	w.syncer = syncer
+
+	w.syncer.Start()
+	return w, w.syncer.Stop
+}
+
+func newShardedWindow(n int) *ShardedWindow {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	w := &ShardedWindow{shards: make([]shard, n)}
+	w.indexes.New = func() interface{} {
+		i := int(atomic.AddUint32(&w.next, 1)-1) % n
+		return &i
+	}
+	return w
+}
+
+func (w *ShardedWindow) Start() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&w.start))
+}
+
+func (w *ShardedWindow) Count() int64 {
+	return atomic.LoadInt64(&w.base) + w.added()
+}
+
+// added returns the total count added by this window itself.
+func (w *ShardedWindow) added() int64 {
+	var n int64
+	for i := range w.shards {
+		n += atomic.LoadInt64(&w.shards[i].n)
+	}
+	return n
+}
+
+func (w *ShardedWindow) AddCount(n int64) {
+	i := w.indexes.Get().(*int)
+	atomic.AddInt64(&w.shards[*i].n, n)
+	w.indexes.Put(i)
+}
+
+func (w *ShardedWindow) tryAddCount(n, max int64) bool {
+	if w.Count()+n > max {
+		return false
+	}
+	w.AddCount(n)
+	return true
+}
+
+func (w *ShardedWindow) Reset(s time.Time, c int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	atomic.StoreInt64(&w.start, s.UnixNano())
+	atomic.StoreInt64(&w.base, c)
+	for i := range w.shards {
+		atomic.StoreInt64(&w.shards[i].n, 0)
+	}
+
+	// Clear changes accumulated within the OLD window, see SyncWindow.Reset.
+	atomic.StoreInt64(&w.synced, 0)
+}
+
+func (w *ShardedWindow) makeSyncRequest() SyncRequest {
+	added := w.added()
+	return SyncRequest{
+		Key:     w.key,
+		Start:   atomic.LoadInt64(&w.start),
+		Count:   atomic.LoadInt64(&w.base) + added,
+		Changes: added - atomic.LoadInt64(&w.synced),
+	}
+}
+
+func (w *ShardedWindow) handleSyncResponse(resp SyncResponse) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if resp.OK && resp.Start == atomic.LoadInt64(&w.start) {
+		// Update the state of the window, only when it has not been reset
+		// during the latest sync.
+
+		// Take the changes accumulated by other limiters into consideration.
+		atomic.AddInt64(&w.base, resp.OtherChanges)
+
+		// Mark the amount that has been synced.
+		atomic.AddInt64(&w.synced, resp.Changes)
+	}
+}
+
+// Sync tries to sync counter data, if the window is created by
+// NewShardedSyncWindow. Only one of the concurrent callers will actually
+// do the synchronization, while the others return immediately.
+func (w *ShardedWindow) Sync(now time.Time) {
+	if w.syncer == nil {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&w.syncing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&w.syncing, 0)
+
+	w.syncer.Sync(now, w.makeSyncRequest, w.handleSyncResponse)
+}
+
+func (w *ShardedWindow) pending() int64 {
+	if w.syncer == nil {
+		return 0
+	}
+	return w.added() - atomic.LoadInt64(&w.synced)
+}
+
+func (w *ShardedWindow) flush() {
+	f, ok := w.syncer.(syncFlusher)
+	if !ok {
+		return
+	}
+
+	// Wait for the concurrent synchronization to complete.
+	for !atomic.CompareAndSwapInt32(&w.syncing, 0, 1) {
+		runtime.Gosched()
+	}
+	defer atomic.StoreInt32(&w.syncing, 0)
+
+	f.flush(w.makeSyncRequest, w.handleSyncResponse)
+}
+
+func (w *ShardedWindow) clear(starts ...int64) error {
+	if w.syncer == nil {
+		return nil
+	}
+	c, ok := w.syncer.(syncClearer)
+	if !ok {
+		return errNotClearable
+	}
+	return c.clear(w.key, starts...)
+}
@@ -0,0 +1,154 @@
+package slidingwindow
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter_ShardedWindow_AllowN(t *testing.T) {
+	lim, _ := NewLimiter(size, limit, func() (Window, StopFunc) {
+		return NewShardedWindow(4)
+	})
+
+	cases := []caseArg{
+		// prev-window: empty, count: 0
+		// curr-window: [t0, t0 + 1s), count: 0
+		{t0, 1, true},
+		{t1, 2, true},
+		{t2, 3, true},
+		{t5, 5, false}, // count will be (1 + 2 + 3 + 5) = 11, so it fails
+
+		// prev-window: [t0, t0 + 1s), count: 6
+		// curr-window: [t10, t10 + 1s), count: 0
+		{t10, 2, true},
+		{t12, 5, false}, // count will be (4/5*6 + 2 + 5) ≈ 11, so it fails
+		{t15, 5, true},
+
+		// prev-window: [t30 - 1s, t30), count: 0
+		// curr-window: [t30, t30 + 1s), count: 0
+		{t30, 10, true},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := lim.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("lim.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+}
+
+func TestLimiter_ShardedSyncWindow_AllowN(t *testing.T) {
+	store := newMemDatastore()
+	newWindow := func() (Window, StopFunc) {
+		return NewShardedSyncWindow("test", NewBlockingSynchronizer(store, 0), 4)
+	}
+
+	lim1, stop1 := NewLimiter(size, limit, newWindow)
+	defer stop1()
+	lim2, stop2 := NewLimiter(size, limit, newWindow)
+	defer stop2()
+
+	cases := []struct {
+		lim *Limiter
+		caseArg
+	}{
+		{lim1, caseArg{t0, 4, true}}, // datastore: 4
+		{lim2, caseArg{t0, 4, true}}, // datastore: 8
+		{lim1, caseArg{t1, 3, true}}, // datastore: 11
+		{lim1, caseArg{t2, 1, false}},
+		{lim2, caseArg{t2, 1, true}}, // datastore: 12, lim2 has not seen the latest 3 yet
+		{lim2, caseArg{t3, 1, false}},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := c.lim.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("lim.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+
+	// Each change has been synced exactly once.
+	count, _ := store.Get("test", t0.UnixNano())
+	if count != 12 {
+		t.Errorf("store.Get() = %d, want: 12", count)
+	}
+}
+
+func TestShardedWindow_AddCount_Concurrent(t *testing.T) {
+	w, _ := NewShardedWindow(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				w.AddCount(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := w.Count(); got != 8000 {
+		t.Errorf("w.Count() = %d, want: 8000", got)
+	}
+}
+
+// echoSynchronizer responds to every sync request right away, as if other
+// limiters had added one event to the window of the request.
+type echoSynchronizer struct {
+	mu     sync.Mutex
+	counts map[int64]int64 // The number of responses per window.
+}
+
+func (s *echoSynchronizer) Start() {}
+
+func (s *echoSynchronizer) Stop() {}
+
+func (s *echoSynchronizer) Sync(now time.Time, makeReq MakeFunc, handleResp HandleFunc) {
+	req := makeReq()
+	s.mu.Lock()
+	s.counts[req.Start]++
+	s.mu.Unlock()
+	handleResp(SyncResponse{OK: true, Start: req.Start, OtherChanges: 1})
+}
+
+func TestShardedWindow_Reset_Sync(t *testing.T) {
+	syncer := &echoSynchronizer{counts: make(map[int64]int64)}
+	w, _ := NewShardedSyncWindow("test", syncer, 0)
+
+	stopC := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stopC:
+				return
+			default:
+				w.Sync(time.Time{})
+			}
+		}
+	}()
+
+	var last int64
+	for last = 1; last <= 10000; last++ {
+		w.Reset(time.Unix(0, last), 0)
+	}
+	last--
+	close(stopC)
+	wg.Wait()
+
+	// Only the responses of the last window are counted in it.
+	if got, want := w.Count(), syncer.counts[last]; got != want {
+		t.Errorf("w.Count() = %d, want: %d", got, want)
+	}
+}
@@ -0,0 +1,383 @@
+// Package simulation runs many virtual nodes, each of which has a limiter
+// synchronized with a shared datastore, on a virtual clock. The datastore
+// operations take a configurable latency (with jitter), and all the events
+// are processed one by one in the order of time, so a simulation is fully
+// reproducible given the same config and seed, regardless of the load of the
+// machine. This makes it suitable for exploring the trade-offs between the
+// sync interval, the latency and the accuracy:
+//
+//	r := simulation.Run(simulation.Config{
+//	    Nodes:        10,
+//	    Size:         time.Second,
+//	    Limit:        100,
+//	    SyncInterval: 200 * time.Millisecond,
+//	    Latency:      5 * time.Millisecond,
+//	    Seed:         1,
+//	}, simulation.Load{Rate: 20, Duration: time.Minute})
+//	fmt.Println(r.MaxOvershoot)
+//
+// For scripted scenarios, drive a Simulator directly by AllowN and Advance.
+package simulation
+
+import (
+	"container/heap"
+	"math/rand"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// key is the key of the limiters of all the nodes.
+const key = "simulation"
+
+// Config is the config of a simulation.
+type Config struct {
+	// The number of nodes.
+	Nodes int
+
+	// The window size and the limit of the limiter on each node.
+	Size  time.Duration
+	Limit int64
+
+	// Whether to use BlockingSynchronizer instead of NonblockingSynchronizer.
+	Blocking     bool
+	SyncInterval time.Duration
+
+	// The round-trip time of a datastore operation, which deviates randomly
+	// within [-Jitter, Jitter].
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// The seed of the randomness, i.e. the jitter and the arrivals of Run.
+	Seed int64
+
+	// The initial time of the virtual clock. Zero means the Unix epoch.
+	Start time.Time
+}
+
+// event is a scheduled event. Events happening at the same time are
+// processed in the order they are scheduled.
+type event struct {
+	at   time.Time
+	seq  int64
+	fire func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// node is a virtual node.
+type node struct {
+	lim  *sw.Limiter
+	stop sw.StopFunc
+
+	// Until when the node is blocked by a blocking synchronization.
+	blockedUntil time.Time
+}
+
+// Simulator is a simulation in progress. It must be driven by a single
+// goroutine.
+//
+// In the non-blocking mode, each node syncs with a synchronizer that behaves
+// as NonblockingSynchronizer, except that the datastore operations are events
+// on the virtual clock instead of calls made by a goroutine. An operation
+// takes effect when it completes, i.e. after the latency, and its response is
+// taken by the next sync of the node. In the blocking mode, since the node
+// itself is waiting for the operation, the operation takes effect right away,
+// while the node is blocked for the latency.
+type Simulator struct {
+	c     Config
+	nodes []*node
+
+	now    time.Time
+	rng    *rand.Rand
+	events eventQueue
+	seq    int64
+	counts map[int64]int64 // The counts of the windows in the datastore.
+	calls  int64           // The number of datastore operations.
+}
+
+// New creates a new simulator with the given config.
+func New(c Config) *Simulator {
+	s := &Simulator{
+		c:      c,
+		now:    c.Start,
+		rng:    rand.New(rand.NewSource(c.Seed)),
+		counts: make(map[int64]int64),
+	}
+	if s.now.IsZero() {
+		s.now = time.Unix(0, 0)
+	}
+
+	for i := 0; i < c.Nodes; i++ {
+		n := &node{}
+		n.lim, n.stop = sw.NewLimiter(c.Size, c.Limit, func() (sw.Window, sw.StopFunc) {
+			if c.Blocking {
+				store := &nodeStore{s: s, n: n}
+				return sw.NewSyncWindow(key, sw.NewBlockingSynchronizer(store, c.SyncInterval))
+			}
+			return sw.NewSyncWindow(key, &nodeSynchronizer{s: s, syncInterval: c.SyncInterval})
+		})
+		s.nodes = append(s.nodes, n)
+	}
+
+	return s
+}
+
+// Now returns the current time of the virtual clock.
+func (s *Simulator) Now() time.Time {
+	return s.now
+}
+
+// AllowN reports whether n events may happen on node i at the current
+// time. If node i is blocked by a synchronization, the clock is advanced
+// until the node is unblocked first.
+func (s *Simulator) AllowN(i int, n int64) bool {
+	nd := s.nodes[i]
+	if nd.blockedUntil.After(s.now) {
+		s.AdvanceTo(nd.blockedUntil)
+	}
+	return nd.lim.AllowN(s.now, n)
+}
+
+// Advance advances the clock by d, and processes the events in between.
+func (s *Simulator) Advance(d time.Duration) {
+	s.AdvanceTo(s.now.Add(d))
+}
+
+// AdvanceTo advances the clock to t, and processes the events up to t.
+func (s *Simulator) AdvanceTo(t time.Time) {
+	for s.step(t) {
+	}
+}
+
+// step processes the next event, if it happens no later than t, and reports
+// whether there is one. Otherwise the clock is advanced to t.
+func (s *Simulator) step(t time.Time) bool {
+	if len(s.events) == 0 || s.events[0].at.After(t) {
+		if t.After(s.now) {
+			s.now = t
+		}
+		return false
+	}
+	e := heap.Pop(&s.events).(*event)
+	s.now = e.at
+	e.fire()
+	return true
+}
+
+// schedule schedules f to be fired at time at.
+func (s *Simulator) schedule(at time.Time, f func()) {
+	s.seq++
+	heap.Push(&s.events, &event{at: at, seq: s.seq, fire: f})
+}
+
+// Count returns the count of the window starting at start, in the datastore.
+func (s *Simulator) Count(start time.Time) int64 {
+	return s.counts[start.UnixNano()]
+}
+
+// Calls returns the number of datastore operations (i.e. sync round-trips)
+// that have been made.
+func (s *Simulator) Calls() int64 {
+	return s.calls
+}
+
+// Close completes the pending datastore operations, and then stops all the
+// nodes.
+func (s *Simulator) Close() {
+	for len(s.events) > 0 {
+		last := s.events[0].at
+		for _, e := range s.events {
+			if e.at.After(last) {
+				last = e.at
+			}
+		}
+		s.AdvanceTo(last)
+	}
+	for _, n := range s.nodes {
+		n.stop()
+	}
+}
+
+// latency returns a random latency.
+func (s *Simulator) latency() time.Duration {
+	d := s.c.Latency
+	if s.c.Jitter > 0 {
+		d += time.Duration(s.rng.Int63n(int64(2*s.c.Jitter)+1)) - s.c.Jitter
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// do adds delta to the count of the window starting at start, and returns
+// the new count.
+func (s *Simulator) do(start, delta int64) int64 {
+	s.calls++
+	s.counts[start] += delta
+	return s.counts[start]
+}
+
+// nodeStore is the datastore seen by a node in the blocking mode.
+type nodeStore struct {
+	s *Simulator
+	n *node
+}
+
+func (d *nodeStore) Add(key string, start, delta int64) (int64, error) {
+	d.n.blockedUntil = d.s.now.Add(d.s.latency())
+	return d.s.do(start, delta), nil
+}
+
+func (d *nodeStore) Get(key string, start int64) (int64, error) {
+	return d.Add(key, start, 0)
+}
+
+// nodeSynchronizer is the synchronizer of a node in the non-blocking mode.
+// Just like NonblockingSynchronizer, it sends a request once the sync interval
+// has elapsed since the last one, and takes the response (if completed) on
+// the same or a later call to Sync.
+type nodeSynchronizer struct {
+	s            *Simulator
+	syncInterval time.Duration
+
+	inProgress bool
+	lastSynced time.Time
+	resp       *sw.SyncResponse // The response, once the operation completes.
+}
+
+func (y *nodeSynchronizer) Start() {}
+
+func (y *nodeSynchronizer) Stop() {}
+
+func (y *nodeSynchronizer) Sync(now time.Time, makeReq sw.MakeFunc, handleResp sw.HandleFunc) {
+	if !y.inProgress && now.Sub(y.lastSynced) >= y.syncInterval {
+		y.inProgress = true
+		y.lastSynced = now
+
+		req := makeReq()
+		y.s.schedule(y.s.now.Add(y.s.latency()), func() {
+			newCount := y.s.do(req.Start, req.Changes)
+			y.resp = &sw.SyncResponse{
+				OK:           true,
+				Start:        req.Start,
+				Changes:      req.Changes,
+				OtherChanges: newCount - req.Count,
+			}
+		})
+	}
+
+	if y.inProgress && y.resp != nil {
+		resp := *y.resp
+		y.inProgress = false
+		y.resp = nil
+		handleResp(resp)
+	}
+}
+
+// Load is the load of Run, where the events arrive at each node as a
+// Poisson process.
+type Load struct {
+	Rate     float64 // The average number of arrivals per second at each node.
+	Duration time.Duration
+}
+
+// Window is the result of a window.
+type Window struct {
+	Start   time.Time
+	Allowed int64
+}
+
+// Result is the result of Run.
+type Result struct {
+	Offered int64
+	Allowed int64
+
+	// The windows entirely covered by the load.
+	Windows []Window
+
+	// The maximum number of events allowed beyond the limit in a window.
+	MaxOvershoot int64
+
+	// The number of datastore operations (i.e. sync round-trips).
+	Calls int64
+}
+
+// Run runs a simulation with config c under load.
+func Run(c Config, load Load) *Result {
+	s := New(c)
+	start := s.Now()
+	end := start.Add(load.Duration)
+
+	r := &Result{}
+	allowed := make(map[int64]int64) // The allowed events per window.
+
+	// The arrivals use their own randomness, so that they stay the same
+	// when the other parts of the config change.
+	rng := rand.New(rand.NewSource(c.Seed + 1))
+	next := func() time.Duration {
+		return time.Duration(rng.ExpFloat64() / load.Rate * float64(time.Second))
+	}
+
+	var arrive func(i int) func()
+	arrive = func(i int) func() {
+		return func() {
+			if blocked := s.nodes[i].blockedUntil; blocked.After(s.Now()) {
+				// Delay the arrival until the node is unblocked.
+				s.schedule(blocked, arrive(i))
+				return
+			}
+
+			r.Offered++
+			if s.AllowN(i, 1) {
+				r.Allowed++
+				allowed[s.Now().Truncate(c.Size).UnixNano()]++
+			}
+			if at := s.Now().Add(next()); at.Before(end) {
+				s.schedule(at, arrive(i))
+			}
+		}
+	}
+	for i := 0; i < c.Nodes; i++ {
+		if at := start.Add(next()); at.Before(end) {
+			s.schedule(at, arrive(i))
+		}
+	}
+
+	s.AdvanceTo(end)
+	s.Close()
+	r.Calls = s.Calls()
+
+	for ws := start.Truncate(c.Size); ws.Before(end); ws = ws.Add(c.Size) {
+		if ws.Before(start) || ws.Add(c.Size).After(end) {
+			continue // Partially covered.
+		}
+		n := allowed[ws.UnixNano()]
+		r.Windows = append(r.Windows, Window{Start: ws, Allowed: n})
+		if n-c.Limit > r.MaxOvershoot {
+			r.MaxOvershoot = n - c.Limit
+		}
+	}
+	return r
+}
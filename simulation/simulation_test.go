@@ -0,0 +1,110 @@
+package simulation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSimulator(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		node    int
+		n       int64
+		ok      bool
+	}
+
+	cases := []struct {
+		name     string
+		blocking bool
+		steps    []step
+	}{
+		{
+			name: "nonblocking",
+			steps: []step{
+				{0, 0, 6, true},                     // Send the changes of node 0.
+				{0, 1, 0, true},                     // Send the request of node 1, which gets 6 at 10ms.
+				{0, 1, 3, true},                     // Node 1 has not seen the changes of node 0 yet.
+				{10 * time.Millisecond, 1, 0, true}, // Take the response.
+				{0, 1, 2, false},                    // 3 + 6 + 2 > 10
+				{0, 1, 1, true},
+				{200 * time.Millisecond, 1, 0, true}, // Send the changes of node 1.
+				{0, 0, 0, true},                      // Take the previous response.
+				{0, 0, 0, true},                      // Send the request of node 0, which gets 10 at 220ms.
+				{10 * time.Millisecond, 0, 0, true},  // Take the response.
+				{0, 0, 1, false},
+			},
+		},
+		{
+			name:     "blocking",
+			blocking: true,
+			steps: []step{
+				{0, 0, 6, true},  // Sync the changes of node 0 right away.
+				{0, 1, 0, true},  // Get the count of node 0.
+				{0, 1, 6, false}, // Wait until 10ms, when node 1 is unblocked.
+				{0, 1, 4, true},
+				{200 * time.Millisecond, 1, 0, true}, // Sync the changes of node 1.
+				{0, 0, 0, true},                      // Get the count of node 1.
+				{0, 0, 1, false},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := New(Config{
+				Nodes:        2,
+				Size:         time.Second,
+				Limit:        10,
+				Blocking:     c.blocking,
+				SyncInterval: 100 * time.Millisecond,
+				Latency:      10 * time.Millisecond,
+			})
+			defer s.Close()
+
+			for i, st := range c.steps {
+				s.Advance(st.advance)
+				if ok := s.AllowN(st.node, st.n); ok != st.ok {
+					t.Fatalf("#%d (%v): AllowN(%d, %d) = %v, want: %v", i, s.Now(), st.node, st.n, ok, st.ok)
+				}
+			}
+
+			if got := s.Count(time.Unix(0, 0)); got != 10 {
+				t.Errorf("Count: got %d, want: 10", got)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, blocking := range []bool{false, true} {
+		c := Config{
+			Nodes:        5,
+			Size:         time.Second,
+			Limit:        50,
+			Blocking:     blocking,
+			SyncInterval: 200 * time.Millisecond,
+			Latency:      5 * time.Millisecond,
+			Jitter:       3 * time.Millisecond,
+			Seed:         1,
+		}
+		load := Load{Rate: 20, Duration: 10 * time.Second}
+
+		r1 := Run(c, load)
+		r2 := Run(c, load)
+		if !reflect.DeepEqual(r1, r2) {
+			t.Fatalf("blocking=%v: got different results %+v and %+v", blocking, r1, r2)
+		}
+
+		if len(r1.Windows) != 10 {
+			t.Errorf("blocking=%v: got %d windows, want: 10", blocking, len(r1.Windows))
+		}
+		if r1.Allowed >= r1.Offered || r1.Calls == 0 {
+			t.Errorf("blocking=%v: unexpected result %+v", blocking, r1)
+		}
+		// 100 events are offered per second, so the limit is exceeded
+		// only slightly, in spite of the sync interval.
+		if r1.MaxOvershoot > c.Limit/2 {
+			t.Errorf("blocking=%v: got max overshoot %d, want: <= %d", blocking, r1.MaxOvershoot, c.Limit/2)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package slidingwindow
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,6 +27,46 @@ type Window interface {
 	Sync(now time.Time)
 }
 
+// concurrentWindow is implemented by windows whose counts can be changed,
+// and whose sync behaviour can be triggered, by concurrent callers without
+// holding the limiter's lock.
+type concurrentWindow interface {
+	Window
+
+	// tryAddCount increments the accumulated count by n, only if the
+	// resulting count will not exceed max. It reports whether the count
+	// has been incremented.
+	tryAddCount(n, max int64) bool
+}
+
+// migrator is implemented by windows that need to take extra actions,
+// other than Reset, to carry migrated counts over to the new window.
+type migrator interface {
+	// Migrate moves the window to the start s with the migrated count c.
+	Migrate(s time.Time, c int64)
+}
+
+// flusher is implemented by windows that are able to sync their pending
+// changes to the central datastore right away.
+type flusher interface {
+	flush()
+}
+
+// pendingWindow is implemented by windows that accumulate the changes to
+// be synced to the central datastore.
+type pendingWindow interface {
+	// pending returns the changes that have not been synced yet.
+	pending() int64
+}
+
+// clearer is implemented by windows whose counts are kept in the central
+// datastore.
+type clearer interface {
+	// clear clears the counts of the windows represented by starts in the
+	// central datastore.
+	clear(starts ...int64) error
+}
+
 // StopFunc stops the window's sync behaviour.
 type StopFunc func()
 
@@ -32,18 +75,51 @@ type StopFunc func()
 type NewWindow func() (Window, StopFunc)
 
 type Limiter struct {
-	size  time.Duration
-	limit int64
+	// The unique id of the limiter, which decides the order of locking
+	// multiple limiters (see Hierarchy).
+	id uint64
+
+	boundary Boundary
+	limit    int64 // accessed atomically
 
 	mu sync.Mutex
 
 	curr Window
 	prev Window
+
+	// If the current window is a concurrent one, decisions are made without
+	// holding mu (see allowConcurrent), by using it and the latest epoch.
+	concurrent concurrentWindow
+	epoch      atomic.Value // *epoch
+
+	priorities [numPriorities]priorityClass
+}
+
+// lastLimiterID is the id of the latest limiter created.
+var lastLimiterID uint64
+
+// epoch is an immutable snapshot of the windows' geometry, which is published
+// whenever the windows are changed under mu.
+type epoch struct {
+	// The boundaries (timestamps in nanoseconds) of the current window.
+	// [start, end)
+	start int64
+	end   int64
+
+	// The count of the previous window.
+	prevCount int64
 }
 
 // NewLimiter creates a new limiter, and returns a function to stop
 // the possible sync behaviour within the current window.
 func NewLimiter(size time.Duration, limit int64, newWindow NewWindow) (*Limiter, StopFunc) {
+	return NewBoundaryLimiter(Every(size), limit, newWindow)
+}
+
+// NewBoundaryLimiter creates a new limiter whose windows are aligned by
+// the given boundary policy (e.g. calendar days or months), and returns
+// a function to stop the possible sync behaviour within the current window.
+func NewBoundaryLimiter(boundary Boundary, limit int64, newWindow NewWindow) (*Limiter, StopFunc) {
 	currWin, currStop := newWindow()
 
 	// The previous window is static (i.e. no add changes will happen within it),
@@ -55,34 +131,127 @@ func NewLimiter(size time.Duration, limit int64, newWindow NewWindow) (*Limiter,
 	prevWin, _ := NewLocalWindow()
 
 	lim := &Limiter{
-		size:  size,
-		limit: limit,
-		curr:  currWin,
-		prev:  prevWin,
+		id:       atomic.AddUint64(&lastLimiterID, 1),
+		boundary: boundary,
+		limit:    limit,
+		curr:     currWin,
+		prev:     prevWin,
+	}
+	switch w := currWin.(type) {
+	case *LocalWindow:
+		lim.concurrent = w
+	case *ShardedWindow:
+		lim.concurrent = w
 	}
+	lim.publish()
 
 	return lim, currStop
 }
 
-// Size returns the time duration of one window size. Note that the size
-// is defined to be read-only, if you need to change the size,
-// create a new limiter with a new size instead.
+// Size returns the time duration of the current window, which is always
+// the same for limiters created by NewLimiter.
 func (lim *Limiter) Size() time.Duration {
-	return lim.size
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.currSize()
 }
 
-// Limit returns the maximum events permitted to happen during one window size.
-func (lim *Limiter) Limit() int64 {
+// SetSize changes the window size of the limiter at time.Now(), while
+// keeping the type of its boundary policy (e.g. the offset of Offset), see
+// SetBoundary. It reports false, without any change, if the window sizes
+// are decided by the calendar (e.g. Daily), which is up to SetBoundary.
+func (lim *Limiter) SetSize(newSize time.Duration) bool {
 	lim.mu.Lock()
 	defer lim.mu.Unlock()
-	return lim.limit
+
+	boundary, ok := resize(lim.boundary, newSize)
+	if !ok {
+		return false
+	}
+	lim.setBoundary(time.Now(), boundary)
+	return true
 }
 
-// SetLimit sets a new Limit for the limiter.
-func (lim *Limiter) SetLimit(newLimit int64) {
+// SetBoundary changes the boundary policy of the limiter at time now.
+//
+// Instead of starting over with empty windows, the counts of the old windows
+// are migrated to the new ones proportionally, by assuming that the events
+// are evenly distributed within each old window.
+func (lim *Limiter) SetBoundary(now time.Time, boundary Boundary) {
 	lim.mu.Lock()
 	defer lim.mu.Unlock()
-	lim.limit = newLimit
+	lim.setBoundary(now, boundary)
+}
+
+// setBoundary is the version of SetBoundary that must be called with mu held.
+func (lim *Limiter) setBoundary(now time.Time, boundary Boundary) {
+	lim.advance(now)
+
+	currStart, prevStart := lim.curr.Start(), lim.prev.Start()
+	currCount, prevCount := lim.curr.Count(), lim.prev.Count()
+
+	// estimate returns the estimated count of events happened within [s, e).
+	estimate := func(s, e time.Time) int64 {
+		count := float64(prevCount) * overlap(s, e, prevStart, currStart)
+		if now.After(currStart) {
+			count += float64(currCount) * overlap(s, e, currStart, now)
+		} else if !currStart.Before(s) && currStart.Before(e) {
+			// All the events of the current-window happened at the very start.
+			count += float64(currCount)
+		}
+		return int64(count)
+	}
+
+	newCurrStart := boundary.Truncate(now)
+	newPrevStart := boundary.Truncate(newCurrStart.Add(-1))
+
+	// The events happened at time now also belong to the new current-window.
+	newCurrCount := estimate(newCurrStart, now.Add(1))
+	newPrevCount := estimate(newPrevStart, newCurrStart)
+
+	lim.prev.Reset(newPrevStart, newPrevCount)
+	if m, ok := lim.curr.(migrator); ok {
+		m.Migrate(newCurrStart, newCurrCount)
+	} else {
+		lim.curr.Reset(newCurrStart, newCurrCount)
+	}
+
+	lim.boundary = boundary
+	lim.publish()
+}
+
+// overlap returns the ratio of the part of [ws, we) overlapped by [s, e)
+// to the whole [ws, we).
+func overlap(s, e, ws, we time.Time) float64 {
+	if !ws.Before(we) {
+		return 0
+	}
+	if s.Before(ws) {
+		s = ws
+	}
+	if e.After(we) {
+		e = we
+	}
+	if !s.Before(e) {
+		return 0
+	}
+	return float64(e.Sub(s)) / float64(we.Sub(ws))
+}
+
+// currSize returns the time duration of the current window.
+func (lim *Limiter) currSize() time.Duration {
+	start := lim.curr.Start()
+	return lim.boundary.Next(start).Sub(start)
+}
+
+// Limit returns the maximum events permitted to happen during one window size.
+func (lim *Limiter) Limit() int64 {
+	return atomic.LoadInt64(&lim.limit)
+}
+
+// SetLimit sets a new Limit for the limiter.
+func (lim *Limiter) SetLimit(newLimit int64) {
+	atomic.StoreInt64(&lim.limit, newLimit)
 }
 
 // Allow is shorthand for AllowN(time.Now(), 1).
@@ -92,37 +261,253 @@ func (lim *Limiter) Allow() bool {
 
 // AllowN reports whether n events may happen at time now.
 func (lim *Limiter) AllowN(now time.Time, n int64) bool {
+	return lim.allowN(now, n, lim.Limit())
+}
+
+// allowN reports whether n events may happen at time now, provided that
+// at most limit events are permitted during one window size.
+func (lim *Limiter) allowN(now time.Time, n, limit int64) bool {
+	if lim.concurrent != nil {
+		return lim.allowConcurrent(now, n, limit)
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	lim.advance(now)
+	count := lim.weightedCount(now)
+
+	// Trigger the possible sync behaviour.
+	defer lim.curr.Sync(now)
+
+	if count+n > limit {
+		return false
+	}
+
+	lim.curr.AddCount(n)
+	return true
+}
+
+// Count returns the approximate count of events during the sliding window
+// that ends at time now.
+func (lim *Limiter) Count(now time.Time) int64 {
 	lim.mu.Lock()
 	defer lim.mu.Unlock()
 
 	lim.advance(now)
+	return lim.weightedCount(now)
+}
+
+// weightedCount returns the count of the current window, plus the count of
+// the previous window weighted by its part overlapped by the sliding window.
+//
+// Note that weightedCount must be called with mu held.
+func (lim *Limiter) weightedCount(now time.Time) int64 {
+	return lim.weightedPrevCount(now) + lim.curr.Count()
+}
 
+// weightedPrevCount returns the count of the previous window weighted by its
+// part overlapped by the sliding window.
+//
+// Note that weightedPrevCount must be called with mu held.
+func (lim *Limiter) weightedPrevCount(now time.Time) int64 {
+	size := lim.currSize()
 	elapsed := now.Sub(lim.curr.Start())
-	weight := float64(lim.size-elapsed) / float64(lim.size)
-	count := int64(weight*float64(lim.prev.Count())) + lim.curr.Count()
+	weight := float64(size-elapsed) / float64(size)
+	return int64(weight * float64(lim.prev.Count()))
+}
+
+// consumeLocked is like AllowN, except that it must be called with mu held,
+// which keeps the windows from advancing in the meantime. Thus the consumed
+// events can be given back exactly by lim.curr.AddCount(-n), as long as mu
+// is still held.
+func (lim *Limiter) consumeLocked(now time.Time, n int64) bool {
+	lim.advance(now)
+	max := lim.Limit() - lim.weightedPrevCount(now)
 
 	// Trigger the possible sync behaviour.
 	defer lim.curr.Sync(now)
 
-	if count+n > lim.limit {
-		return false
+	if lim.concurrent != nil {
+		// Others may still add counts without holding mu.
+		return lim.concurrent.tryAddCount(n, max)
 	}
 
+	if lim.curr.Count()+n > max {
+		return false
+	}
 	lim.curr.AddCount(n)
 	return true
 }
 
+// refundN gives back n events that have been permitted at time now, which
+// only takes effect if the window that they were counted in is still the
+// current window.
+func (lim *Limiter) refundN(now time.Time, n int64) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	if lim.boundary.Truncate(now).Equal(lim.curr.Start()) {
+		lim.curr.AddCount(-n)
+	}
+}
+
+// Flush syncs the pending changes of the current window to the central
+// datastore right away, if the window supports it (e.g. SyncWindow). It
+// should be called before stopping the limiter (e.g. on shutdown), since
+// the changes that have not been synced will be lost then.
+func (lim *Limiter) Flush() {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	if f, ok := lim.curr.(flusher); ok {
+		f.flush()
+	}
+}
+
+// ClearStore clears the counts of the current and previous windows, as of
+// time now, in the central datastore, if the windows sync with one (e.g.
+// SyncWindow). The pending changes are synced beforehand, so that no
+// synchronization in flight adds them back afterwards.
+//
+// Note that the local counts are left unchanged, thus the limiter is meant
+// to be discarded afterwards (e.g. by KeyedLimiter.Remove). The counts are
+// deleted if the datastore is a ListDatastore, or offset by adding the negated
+// counts otherwise (e.g. the Node of package gossip). ClearStore fails if the
+// synchronizer is neither BlockingSynchronizer nor NonblockingSynchronizer
+// (e.g. a custom one), or if the datastore fails.
+func (lim *Limiter) ClearStore(now time.Time) error {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	c, ok := lim.curr.(clearer)
+	if !ok {
+		return nil
+	}
+
+	lim.advance(now)
+	if f, ok := lim.curr.(flusher); ok {
+		f.flush()
+	}
+	return c.clear(lim.prev.Start().UnixNano(), lim.curr.Start().UnixNano())
+}
+
+// Pending returns the changes of the current window that have not been
+// synced to the central datastore yet, which is always zero for windows
+// that never sync (e.g. LocalWindow).
+func (lim *Limiter) Pending() int64 {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	if w, ok := lim.curr.(pendingWindow); ok {
+		return w.pending()
+	}
+	return 0
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (lim *Limiter) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events may happen, or ctx is done. It returns
+// an error if n exceeds the limit, since such events will never be allowed.
+//
+// Note that the availability is checked by polling AllowN, which in turn
+// drives the possible sync behaviour during the wait.
+func (lim *Limiter) WaitN(ctx context.Context, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+
+	for {
+		limit := lim.Limit()
+		if n > limit {
+			return fmt.Errorf("slidingwindow: n %d exceeds limit %d", n, limit)
+		}
+
+		now := time.Now()
+		if lim.AllowN(now, n) {
+			return nil
+		}
+
+		// Wait for roughly the time that n events take at the limited rate,
+		// and check again.
+		size := lim.Size()
+		delay := time.Duration(float64(size) / float64(limit) * float64(n))
+		if delay > size {
+			delay = size
+		}
+		if delay < time.Millisecond {
+			delay = time.Millisecond
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// allowConcurrent is the lock-free version of AllowN, which is used when
+// the current window is a LocalWindow or a ShardedWindow.
+//
+// Only the callers who find the windows outdated will take the lock to
+// advance them, while the others make decisions based on the latest epoch,
+// and add counts concurrently.
+//
+// Note that callers holding an outdated epoch, in the middle of a concurrent
+// advance, may make their decisions with the count of the new current window.
+// This is in line with the inaccuracy of the count snapshot in advance.
+func (lim *Limiter) allowConcurrent(now time.Time, n, limit int64) bool {
+	t := now.UnixNano()
+
+	e := lim.epoch.Load().(*epoch)
+	if t < e.start || t >= e.end {
+		lim.mu.Lock()
+		lim.advance(now)
+		e = lim.epoch.Load().(*epoch)
+		lim.mu.Unlock()
+	}
+
+	weight := float64(e.end-t) / float64(e.end-e.start)
+	prevCount := int64(weight * float64(e.prevCount))
+
+	// Trigger the possible sync behaviour.
+	defer lim.concurrent.Sync(now)
+
+	return lim.concurrent.tryAddCount(n, limit-prevCount)
+}
+
+// publish publishes the latest epoch of the windows, if needed.
+//
+// Note that publish must be called with mu held.
+func (lim *Limiter) publish() {
+	if lim.concurrent == nil {
+		return
+	}
+
+	start := lim.curr.Start()
+	lim.epoch.Store(&epoch{
+		start:     start.UnixNano(),
+		end:       lim.boundary.Next(start).UnixNano(),
+		prevCount: lim.prev.Count(),
+	})
+}
+
 // advance updates the current/previous windows resulting from the passage of time.
 func (lim *Limiter) advance(now time.Time) {
 	// Calculate the start boundary of the expected current-window.
-	newCurrStart := now.Truncate(lim.size)
+	newCurrStart := lim.boundary.Truncate(now)
 
-	diffSize := newCurrStart.Sub(lim.curr.Start()) / lim.size
-	if diffSize >= 1 {
+	if newCurrStart.After(lim.curr.Start()) {
 		// The current-window is at least one-window-size behind the expected one.
 
 		newPrevCount := int64(0)
-		if diffSize == 1 {
+		if lim.boundary.Next(lim.curr.Start()).Equal(newCurrStart) {
 			// The new previous-window will overlap with the old current-window,
 			// so it inherits the count.
 			//
@@ -131,9 +516,12 @@ func (lim *Limiter) advance(now time.Time) {
 			// be inaccurate due to the asynchronous nature of the sync behaviour.
 			newPrevCount = lim.curr.Count()
 		}
-		lim.prev.Reset(newCurrStart.Add(-lim.size), newPrevCount)
+		// The previous-window ends right before the new current-window starts.
+		lim.prev.Reset(lim.boundary.Truncate(newCurrStart.Add(-1)), newPrevCount)
 
 		// The new current-window always has zero count.
 		lim.curr.Reset(newCurrStart, 0)
+
+		lim.publish()
 	}
 }
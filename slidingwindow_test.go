@@ -90,6 +90,115 @@ func TestLimiter_LocalWindow_AllowN(t *testing.T) {
 	}
 }
 
+func TestLimiter_LocalWindow_SetSize(t *testing.T) {
+	lim, _ := NewLimiter(size, limit, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+
+	newSize := 5 * d
+	lim.SetSize(newSize)
+	got := lim.Size()
+	if got != newSize {
+		t.Errorf("lim.Size() = %v, want: %v", got, newSize)
+	}
+}
+
+func TestLimiter_SetSize_KeepsBoundary(t *testing.T) {
+	newWindow := func() (Window, StopFunc) {
+		return NewLocalWindow()
+	}
+
+	lim, _ := NewBoundaryLimiter(Offset(Every(size), 3*d), limit, newWindow)
+	if ok := lim.SetSize(2 * size); !ok {
+		t.Fatalf("Offset: lim.SetSize() = false, want: true")
+	}
+	want := Offset(Every(2*size), 3*d).Truncate(t0)
+	if got := lim.boundary.Truncate(t0); !got.Equal(want) {
+		t.Errorf("Offset: got start %v, want: %v", got, want)
+	}
+
+	lim, _ = NewBoundaryLimiter(Daily(time.UTC), limit, newWindow)
+	if ok := lim.SetSize(size); ok {
+		t.Errorf("Daily: lim.SetSize() = true, want: false")
+	}
+	if got := lim.Size(); got != 24*time.Hour {
+		t.Errorf("Daily: lim.Size() = %v, want: 24h", got)
+	}
+}
+
+func TestLimiter_LocalWindow_SetBoundary(t *testing.T) {
+	lim, _ := NewLimiter(size, limit, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+
+	cases := []caseArg{
+		// prev-window: empty, count: 0
+		// curr-window: [t0, t0 + 1s), count: 0
+		{t0, 4, true},
+		{t5, 4, true},
+	}
+
+	for _, c := range cases {
+		if ok := lim.AllowN(c.t, c.n); ok != c.ok {
+			t.Fatalf("lim.AllowN(%v, %v) = %v, want: %v", c.t, c.n, ok, c.ok)
+		}
+	}
+
+	// The 8 events within [t0, t5] are migrated to the new windows:
+	//
+	// prev-window: [t2, t4), count: 8*2/5 ≈ 3
+	// curr-window: [t4, t6), count: 8*1/5 ≈ 1
+	lim.SetBoundary(t5, Every(2*d))
+
+	cases = []caseArg{
+		{t5, 9, false}, // count will be (1/2*3 + 1 + 9) ≈ 11, so it fails
+		{t5, 8, true},
+		{t5, 1, false},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := lim.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("lim.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+}
+
+func TestLimiter_LocalWindow_AllowN_Concurrent(t *testing.T) {
+	lim, _ := NewLimiter(size, limit*100, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int64
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				// All the events happen within the same window.
+				if lim.AllowN(t5, 1) {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Exactly the limit is permitted, no matter how the callers interleave.
+	if allowed != limit*100 {
+		t.Errorf("allowed = %d, want: %d", allowed, limit*100)
+	}
+}
+
 type MemDatastore struct {
 	data map[string]int64
 	mu   sync.RWMutex
@@ -122,6 +231,165 @@ func (d *MemDatastore) Get(key string, start int64) (int64, error) {
 	return d.data[k], nil
 }
 
+func (d *MemDatastore) CompareAndSet(key string, start, old, new int64) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	k := d.fullKey(key, start)
+	if d.data[k] != old {
+		return false, nil
+	}
+	d.data[k] = new
+	return true, nil
+}
+
+func TestLimiter_SyncWindow_SetBoundary(t *testing.T) {
+	store := newMemDatastore()
+	lim, stop := NewLimiter(size, limit, func() (Window, StopFunc) {
+		return NewSyncWindow("test", NewBlockingSynchronizer(store, 0))
+	})
+	defer stop()
+
+	lim.AllowN(t0, 4)
+	lim.AllowN(t5, 4)
+
+	// The migrated count of the new current-window [t4, t6) is 8*1/5 ≈ 1,
+	// which will be synced to the datastore along with the next event.
+	lim.SetBoundary(t5, Every(2*d))
+	lim.AllowN(t5, 1)
+
+	for _, w := range []struct {
+		start time.Time
+		count int64
+	}{
+		{t0, 8},
+		{t4, 2},
+	} {
+		count, _ := store.Get("test", w.start.UnixNano())
+		if count != w.count {
+			t.Errorf("store.Get(%v) = %d, want: %d", w.start, count, w.count)
+		}
+	}
+}
+
+func TestLimiter_SyncWindow_Flush(t *testing.T) {
+	cases := []struct {
+		name      string
+		newWindow func(store Datastore) (Window, StopFunc)
+	}{
+		{
+			name: "blocking",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewSyncWindow("test", NewBlockingSynchronizer(store, time.Hour))
+			},
+		},
+		{
+			name: "nonblocking",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewSyncWindow("test", NewNonblockingSynchronizer(store, time.Hour))
+			},
+		},
+		{
+			name: "sharded",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewShardedSyncWindow("test", NewBlockingSynchronizer(store, time.Hour), 2)
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := newMemDatastore()
+			lim, stop := NewLimiter(size, limit, func() (Window, StopFunc) {
+				return c.newWindow(store)
+			})
+			defer stop()
+
+			// Only the first event may be synced, due to the long sync interval.
+			lim.AllowN(t0, 3)
+			lim.AllowN(t1, 2)
+
+			if pending := lim.Pending(); pending <= 0 {
+				t.Errorf("lim.Pending() = %d, want: > 0", pending)
+			}
+
+			lim.Flush()
+			if count, _ := store.Get("test", t0.UnixNano()); count != 5 {
+				t.Errorf("store.Get(%v) = %d, want: %d", t0, count, 5)
+			}
+			if pending := lim.Pending(); pending != 0 {
+				t.Errorf("lim.Pending() = %d, want: 0", pending)
+			}
+		})
+	}
+}
+
+func TestLimiter_ClearStore(t *testing.T) {
+	cases := []struct {
+		name      string
+		newWindow func(store Datastore) (Window, StopFunc)
+		wantErr   bool
+	}{
+		{
+			name: "local",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewLocalWindow()
+			},
+		},
+		{
+			name: "blocking",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewSyncWindow("test", NewBlockingSynchronizer(store, time.Hour))
+			},
+		},
+		{
+			name: "nonblocking",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewSyncWindow("test", NewNonblockingSynchronizer(store, time.Hour))
+			},
+		},
+		{
+			name: "sharded",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewShardedSyncWindow("test", NewBlockingSynchronizer(store, time.Hour), 2)
+			},
+		},
+		{
+			name: "unclearable",
+			newWindow: func(store Datastore) (Window, StopFunc) {
+				return NewSyncWindow("test", struct{ Synchronizer }{NewBlockingSynchronizer(store, time.Hour)})
+			},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := newMemDatastore()
+			store.Add("test", t0.UnixNano(), 4) // nolint:errcheck
+			lim, stop := NewLimiter(size, limit, func() (Window, StopFunc) {
+				return c.newWindow(store)
+			})
+			defer stop()
+
+			// The pending events are cleared along with the synced ones.
+			lim.AllowN(t0, 2)
+			lim.AllowN(t10, 1)
+
+			err := lim.ClearStore(t10)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("lim.ClearStore() err = %v, want err: %v", err, c.wantErr)
+			}
+			if c.wantErr || c.name == "local" {
+				return
+			}
+			for _, start := range []time.Time{t0, t10} {
+				if count, _ := store.Get("test", start.UnixNano()); count != 0 {
+					t.Errorf("store.Get(%v) = %d, want: 0", start, count)
+				}
+			}
+		})
+	}
+}
+
 func testSyncWindow(t *testing.T, blockingSync bool, cases []caseArg) {
 	store := newMemDatastore()
 	newWindow := func() (Window, StopFunc) {
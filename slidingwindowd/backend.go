@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/config"
+	"github.com/RussellLuo/slidingwindow/grpcstore"
+	"github.com/RussellLuo/slidingwindow/httpstore"
+	"github.com/RussellLuo/slidingwindow/redisstore"
+	"github.com/go-redis/redis"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// backend is a central datastore, along with the function to release it.
+type backend struct {
+	store sw.Datastore
+	close func() error
+
+	// setMaxSize, if not nil, adapts the datastore to the maximum window
+	// size of the policies using it.
+	setMaxSize func(maxSize time.Duration)
+}
+
+// newBackend creates the backend described by c, whose windows are of size
+// at most maxSize.
+func newBackend(c config.Backend, maxSize time.Duration) (*backend, error) {
+	switch c.Type {
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: c.Addr})
+		store := redisstore.NewRedisDatastore(client, redisTTL(maxSize))
+		return &backend{
+			store: store,
+			close: client.Close,
+			setMaxSize: func(maxSize time.Duration) {
+				store.SetTTL(redisTTL(maxSize))
+			},
+		}, nil
+	case "http":
+		return &backend{store: httpstore.NewClient(c.Addr, nil), close: func() error { return nil }}, nil
+	case "grpc":
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if c.Token != "" {
+			opts = append(opts, grpc.WithUnaryInterceptor(grpcstore.WithToken(c.Token)))
+		}
+		conn, err := grpc.NewClient(c.Addr, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{store: grpcstore.NewClient(conn, time.Second), close: conn.Close}, nil
+	default:
+		return nil, fmt.Errorf("unknown backend type %q", c.Type)
+	}
+}
+
+// redisTTL returns the TTL of the Redis keys for the windows of size at
+// most maxSize. Twice of window size is just enough.
+func redisTTL(maxSize time.Duration) time.Duration {
+	return 2 * maxSize
+}
+
+// maxSizes returns the maximum window size of the policies of f, for each
+// of the backends.
+func maxSizes(f *config.File) map[string]time.Duration {
+	sizes := make(map[string]time.Duration)
+	for _, p := range f.Policies {
+		if p.Backend != "" && p.Size > sizes[p.Backend] {
+			sizes[p.Backend] = p.Size
+		}
+	}
+	return sizes
+}
+
+// template is a key template, e.g. "{user}:{path}".
+type template struct {
+	// The literal texts and the names of parameters, which are interleaved
+	// with each other, starting with a literal text.
+	literals []string
+	params   []string
+}
+
+func parseTemplate(s string) (*template, error) {
+	t := &template{}
+	for {
+		i := strings.IndexByte(s, '{')
+		if i < 0 {
+			break
+		}
+		if strings.ContainsRune(s[:i], '}') {
+			return nil, fmt.Errorf("key template: unopened '}'")
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("key template: unclosed '{'")
+		}
+		name := s[i+1 : i+j]
+		if name == "" || strings.ContainsRune(name, '{') {
+			return nil, fmt.Errorf("key template: bad parameter %q", s[i:i+j+1])
+		}
+		t.literals = append(t.literals, s[:i])
+		t.params = append(t.params, name)
+		s = s[i+j+1:]
+	}
+	if strings.ContainsRune(s, '}') {
+		return nil, fmt.Errorf("key template: unopened '}'")
+	}
+	t.literals = append(t.literals, s)
+	return t, nil
+}
+
+// render fills the template with params.
+func (t *template) render(params map[string]string) (string, error) {
+	var b strings.Builder
+	for i, name := range t.params {
+		v, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing parameter %q", name)
+		}
+		b.WriteString(t.literals[i])
+		b.WriteString(v)
+	}
+	b.WriteString(t.literals[len(t.literals)-1])
+	return b.String(), nil
+}
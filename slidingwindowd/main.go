@@ -0,0 +1,198 @@
+// Command slidingwindowd is a standalone rate-limit daemon, which makes
+// decisions by the named policies loaded from a config file (see package
+// config for the format), e.g.
+//
+//	{
+//	    "backends": {
+//	        "redis": {"type": "redis", "addr": "localhost:6379"}
+//	    },
+//	    "policies": {
+//	        "api": {
+//	            "rate": "100/1m",
+//	            "key": "{user}",
+//	            "backend": "redis",
+//	            "sync_interval": "200ms"
+//	        }
+//	    }
+//	}
+//
+// The decision API is served over both HTTP (POST /v1/allow) and gRPC
+// (see pb/decision.proto), and the Prometheus metrics on /metrics. The
+// admin API (see package admin) is served on a separate address.
+//
+// The policies are reloaded in place whenever the config file changes,
+// while the backends are only loaded on startup.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/admin"
+	"github.com/RussellLuo/slidingwindow/config"
+	"github.com/RussellLuo/slidingwindow/slidingwindowd/pb"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Llongfile)
+
+	var (
+		configFlag = flag.String("config", "slidingwindowd.json", "The path of the config file.")
+		reloadFlag = flag.Duration("reload", 5*time.Second, "The interval of checking the config file for changes.")
+		httpFlag   = flag.String("http", ":8080", "The listen address of the HTTP server.")
+		grpcFlag   = flag.String("grpc", ":9090", "The listen address of the gRPC server. Empty means disabled.")
+		adminFlag  = flag.String("admin", "127.0.0.1:8081", "The listen address of the admin HTTP server. Empty means disabled.")
+		graceFlag  = flag.Duration("grace", 10*time.Second, "The maximum time to wait for the in-flight requests on shutdown.")
+	)
+	flag.Parse()
+
+	f, err := config.Load(*configFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	backends, err := openBackends(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer backends.close()
+
+	s := newServer(config.NewSet(backends.stores()))
+	watcher, err := config.Watch(*configFlag, *reloadFlag, func(f *config.File) error {
+		// Adapt the backends (e.g. the TTL of Redis keys) to the new window
+		// sizes before the limiters start to use them, but only if the new
+		// policies will be applied at all.
+		if _, err := s.validate(f); err != nil {
+			return err
+		}
+		backends.resize(f)
+		return s.apply(f)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	httpServer := &http.Server{Addr: *httpFlag, Handler: s.Handler()}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	var adminServer *http.Server
+	if *adminFlag != "" {
+		adminServer = &http.Server{Addr: *adminFlag, Handler: admin.NewHandler(adminLimiters{s.set})}
+		go func() {
+			if err := adminServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	var grpcServer *grpc.Server
+	if *grpcFlag != "" {
+		lis, err := net.Listen("tcp", *grpcFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grpcServer = grpc.NewServer()
+		pb.RegisterDecisionServer(grpcServer, s)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("received %v, shutting down", <-sigC)
+
+	watcher.Stop()
+
+	// Stop accepting new requests, and wait for the in-flight ones.
+	ctx, cancel := context.WithTimeout(context.Background(), *graceFlag)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("err: %v\n", err)
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			log.Printf("err: %v\n", err)
+		}
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+
+	s.stop()
+}
+
+// backends are the backends opened on startup, keyed by their names.
+type backends map[string]*backend
+
+// openBackends opens the backends of f.
+func openBackends(f *config.File) (backends, error) {
+	sizes := maxSizes(f)
+	bs := make(backends, len(f.Backends))
+	for name, c := range f.Backends {
+		b, err := newBackend(c, sizes[name])
+		if err != nil {
+			bs.close()
+			return nil, err
+		}
+		bs[name] = b
+	}
+	return bs, nil
+}
+
+// stores returns the datastores of the backends.
+func (bs backends) stores() map[string]sw.Datastore {
+	stores := make(map[string]sw.Datastore, len(bs))
+	for name, b := range bs {
+		stores[name] = b.store
+	}
+	return stores
+}
+
+// resize adapts the backends to the window sizes of the policies of f.
+// The backends not used by any policy are left unchanged.
+func (bs backends) resize(f *config.File) {
+	for name, size := range maxSizes(f) {
+		if b, ok := bs[name]; ok && b.setMaxSize != nil {
+			b.setMaxSize(size)
+		}
+	}
+}
+
+// close closes all the backends.
+func (bs backends) close() {
+	for _, b := range bs {
+		if err := b.close(); err != nil {
+			log.Printf("err: %v\n", err)
+		}
+	}
+}
+
+// adminLimiters provides the keyed limiters of the policies to the admin API.
+type adminLimiters struct {
+	set *config.Set
+}
+
+func (a adminLimiters) Names() []string {
+	return a.set.Names()
+}
+
+func (a adminLimiters) Get(name string) (*sw.KeyedLimiter, bool) {
+	_, k, ok := a.set.Get(name)
+	return k, ok
+}
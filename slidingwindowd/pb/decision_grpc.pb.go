@@ -0,0 +1,127 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: decision.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Decision_Allow_FullMethodName = "/slidingwindowd.Decision/Allow"
+)
+
+// DecisionClient is the client API for Decision service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Decision makes rate-limit decisions by the named policies.
+type DecisionClient interface {
+	// Allow reports whether n events may happen now.
+	Allow(ctx context.Context, in *AllowRequest, opts ...grpc.CallOption) (*AllowResponse, error)
+}
+
+type decisionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDecisionClient(cc grpc.ClientConnInterface) DecisionClient {
+	return &decisionClient{cc}
+}
+
+func (c *decisionClient) Allow(ctx context.Context, in *AllowRequest, opts ...grpc.CallOption) (*AllowResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllowResponse)
+	err := c.cc.Invoke(ctx, Decision_Allow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DecisionServer is the server API for Decision service.
+// All implementations must embed UnimplementedDecisionServer
+// for forward compatibility.
+//
+// Decision makes rate-limit decisions by the named policies.
+type DecisionServer interface {
+	// Allow reports whether n events may happen now.
+	Allow(context.Context, *AllowRequest) (*AllowResponse, error)
+	mustEmbedUnimplementedDecisionServer()
+}
+
+// UnimplementedDecisionServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDecisionServer struct{}
+
+func (UnimplementedDecisionServer) Allow(context.Context, *AllowRequest) (*AllowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Allow not implemented")
+}
+func (UnimplementedDecisionServer) mustEmbedUnimplementedDecisionServer() {}
+func (UnimplementedDecisionServer) testEmbeddedByValue()                  {}
+
+// UnsafeDecisionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DecisionServer will
+// result in compilation errors.
+type UnsafeDecisionServer interface {
+	mustEmbedUnimplementedDecisionServer()
+}
+
+func RegisterDecisionServer(s grpc.ServiceRegistrar, srv DecisionServer) {
+	// If the following call pancis, it indicates UnimplementedDecisionServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Decision_ServiceDesc, srv)
+}
+
+func _Decision_Allow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AllowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecisionServer).Allow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Decision_Allow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecisionServer).Allow(ctx, req.(*AllowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Decision_ServiceDesc is the grpc.ServiceDesc for Decision service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Decision_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "slidingwindowd.Decision",
+	HandlerType: (*DecisionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Allow",
+			Handler:    _Decision_Allow_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "decision.proto",
+}
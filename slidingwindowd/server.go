@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/slidingwindow/config"
+	"github.com/RussellLuo/slidingwindow/slidingwindowd/pb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errUnknownPolicy and errBadRequest classify the errors of decisions.
+type (
+	errUnknownPolicy struct{ name string }
+	errBadRequest    struct{ err error }
+)
+
+func (e errUnknownPolicy) Error() string { return fmt.Sprintf("unknown policy %q", e.name) }
+func (e errBadRequest) Error() string    { return e.err.Error() }
+
+// server makes decisions by the policies, over both HTTP and gRPC.
+type server struct {
+	pb.UnimplementedDecisionServer
+
+	set *config.Set
+
+	mu        sync.RWMutex
+	templates map[string]*template // The key templates of the policies.
+
+	registry  *prometheus.Registry
+	decisions *prometheus.CounterVec
+}
+
+func newServer(set *config.Set) *server {
+	s := &server{
+		set:       set,
+		templates: make(map[string]*template),
+		registry:  prometheus.NewRegistry(),
+		decisions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "slidingwindowd",
+				Name:      "decisions_total",
+				Help:      "count of decisions, partitioned by policy and allow result.",
+			},
+			[]string{"policy", "allowed"},
+		),
+	}
+	s.registry.MustRegister(s.decisions)
+	return s
+}
+
+// validate reports an error if the policies of f cannot be applied, without
+// changing anything. It returns the parsed key templates of the policies.
+func (s *server) validate(f *config.File) (map[string]*template, error) {
+	templates := make(map[string]*template, len(f.Policies))
+	for name, p := range f.Policies {
+		t, err := parseTemplate(p.Key)
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %v", name, err)
+		}
+		templates[name] = t
+	}
+	if err := s.set.Validate(f.Policies); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// apply applies the policies of f, which is called whenever the config
+// file changes.
+func (s *server) apply(f *config.File) error {
+	templates, err := s.validate(f)
+	if err != nil {
+		return err
+	}
+
+	if err := s.set.Apply(f.Policies); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.templates = templates
+	s.mu.Unlock()
+	return nil
+}
+
+// decide reports whether n events may happen now, by the named policy.
+func (s *server) decide(name string, params map[string]string, n int64) (*pb.AllowResponse, error) {
+	_, keyed, ok := s.set.Get(name)
+	s.mu.RLock()
+	t := s.templates[name]
+	s.mu.RUnlock()
+	if !ok || t == nil {
+		return nil, errUnknownPolicy{name}
+	}
+	key, err := t.render(params)
+	if err != nil {
+		return nil, errBadRequest{err}
+	}
+	if n <= 0 {
+		n = 1
+	}
+
+	now := time.Now()
+	lim := keyed.Limiter(key)
+	allowed := lim.AllowN(now, n)
+	s.decisions.WithLabelValues(name, strconv.FormatBool(allowed)).Inc()
+
+	remaining := lim.Limit() - lim.Count(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &pb.AllowResponse{
+		Allowed:   allowed,
+		Key:       key,
+		Limit:     lim.Limit(),
+		Remaining: remaining,
+	}, nil
+}
+
+// Allow implements the Decision service.
+func (s *server) Allow(ctx context.Context, req *pb.AllowRequest) (*pb.AllowResponse, error) {
+	resp, err := s.decide(req.GetPolicy(), req.GetParams(), req.GetN())
+	switch err.(type) {
+	case nil:
+		return resp, nil
+	case errUnknownPolicy:
+		return nil, status.Error(codes.NotFound, err.Error())
+	default:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+// allowRequest and allowResponse are the JSON formats of the HTTP API.
+type (
+	allowRequest struct {
+		Policy string            `json:"policy"`
+		Params map[string]string `json:"params"`
+		N      int64             `json:"n"`
+	}
+	allowResponse struct {
+		Allowed   bool   `json:"allowed"`
+		Key       string `json:"key"`
+		Limit     int64  `json:"limit"`
+		Remaining int64  `json:"remaining"`
+	}
+)
+
+// Handler returns the HTTP handler serving the decision API on
+// "POST /v1/allow", and the metrics on "/metrics".
+func (s *server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/allow", s.serveAllow)
+	mux.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+func (s *server) serveAllow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req allowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.decide(req.Policy, req.Params, req.N)
+	switch err.(type) {
+	case nil:
+	case errUnknownPolicy:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Allowed {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	json.NewEncoder(w).Encode(allowResponse{ // nolint:errcheck
+		Allowed:   resp.Allowed,
+		Key:       resp.Key,
+		Limit:     resp.Limit,
+		Remaining: resp.Remaining,
+	})
+}
+
+// stop flushes the pending changes of all the policies, and then stops
+// their synchronizers.
+func (s *server) stop() {
+	s.set.Stop()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/slidingwindow/config"
+	"github.com/RussellLuo/slidingwindow/httpstore"
+	"github.com/RussellLuo/slidingwindow/redisstore"
+	"github.com/RussellLuo/slidingwindow/slidingwindowd/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTemplate(t *testing.T) {
+	cases := []struct {
+		in     string
+		params map[string]string
+		want   string
+		err    bool
+	}{
+		{"global", nil, "global", false},
+		{"{user}", map[string]string{"user": "alice"}, "alice", false},
+		{"u:{user}/{path}!", map[string]string{"user": "alice", "path": "/a"}, "u:alice//a!", false},
+		{"{user}", map[string]string{}, "", true},
+		{"{user", nil, "", true},
+		{"user}", nil, "", true},
+		{"a}{user}", map[string]string{"user": "alice"}, "", true},
+		{"{user}}{path}", map[string]string{"user": "alice", "path": "/a"}, "", true},
+		{"{}", nil, "", true},
+	}
+	for _, c := range cases {
+		tmpl, err := parseTemplate(c.in)
+		if err == nil {
+			var got string
+			got, err = tmpl.render(c.params)
+			if got != c.want {
+				t.Errorf("%q: got %q, want: %q", c.in, got, c.want)
+			}
+		}
+		if (err != nil) != c.err {
+			t.Errorf("%q: got err %v, want err: %v", c.in, err, c.err)
+		}
+	}
+}
+
+func TestBackends_Resize(t *testing.T) {
+	f, err := config.Parse([]byte(`{
+		"backends": {
+			"redis": {"type": "redis", "addr": "127.0.0.1:0"}
+		},
+		"policies": {
+			"a": {"rate": "10/1m", "backend": "redis"},
+			"b": {"rate": "10/1h"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	backends, err := openBackends(f)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer backends.close()
+
+	store := backends["redis"].store.(*redisstore.RedisDatastore)
+	if got := store.TTL(); got != 2*time.Minute {
+		t.Errorf("TTL: got %v, want: %v", got, 2*time.Minute)
+	}
+
+	// The TTL follows the window size of the policies on reload.
+	f.Policies["a"] = config.Policy{Limit: 10, Size: time.Hour, Backend: "redis"}
+	backends.resize(f)
+	if got := store.TTL(); got != 2*time.Hour {
+		t.Errorf("TTL: got %v, want: %v", got, 2*time.Hour)
+	}
+
+	// Unused backends keep their TTL.
+	delete(f.Policies, "a")
+	backends.resize(f)
+	if got := store.TTL(); got != 2*time.Hour {
+		t.Errorf("TTL: got %v, want: %v", got, 2*time.Hour)
+	}
+}
+
+func TestServer(t *testing.T) {
+	store := httpstore.NewMemStore()
+	storeServer := httptest.NewServer(httpstore.NewHandler(store))
+	defer storeServer.Close()
+
+	f, err := config.Parse([]byte(`{
+		"backends": {
+			"central": {"type": "http", "addr": "` + storeServer.URL + `"}
+		},
+		"policies": {
+			"local": {"rate": "2/1m", "key": "{user}"},
+			"synced": {"rate": "10/1h", "key": "{user}", "backend": "central", "sync_interval": "1h"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	backends, err := openBackends(f)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer backends.close()
+
+	s := newServer(config.NewSet(backends.stores()))
+	if err := s.apply(f); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	h := s.Handler()
+	cases := []struct {
+		body      string
+		code      int
+		remaining int64
+	}{
+		{`{"policy": "local", "params": {"user": "alice"}}`, http.StatusOK, 1},
+		{`{"policy": "local", "params": {"user": "alice"}}`, http.StatusOK, 0},
+		{`{"policy": "local", "params": {"user": "alice"}}`, http.StatusTooManyRequests, 0},
+		{`{"policy": "local", "params": {"user": "bob"}, "n": 2}`, http.StatusOK, 0},
+		{`{"policy": "local", "params": {}}`, http.StatusBadRequest, 0},
+		{`{"policy": "unknown"}`, http.StatusNotFound, 0},
+		{`{"policy": "synced", "params": {"user": "alice"}, "n": 3}`, http.StatusOK, 7},
+		{`{"policy": "synced", "params": {"user": "alice"}, "n": 4}`, http.StatusOK, 3},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/allow", strings.NewReader(c.body)))
+		if w.Code != c.code {
+			t.Errorf("%s: got code %d, want: %d", c.body, w.Code, c.code)
+			continue
+		}
+		if c.code == http.StatusOK || c.code == http.StatusTooManyRequests {
+			var resp allowResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("%s: err: %v", c.body, err)
+			}
+			if resp.Remaining != c.remaining {
+				t.Errorf("%s: got remaining %d, want: %d", c.body, resp.Remaining, c.remaining)
+			}
+		}
+	}
+
+	if _, err := s.Allow(context.Background(), &pb.AllowRequest{Policy: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Allow: got err %v, want: %v", err, codes.NotFound)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `slidingwindowd_decisions_total{allowed="false",policy="local"} 1`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("metrics: missing %q in:\n%s", want, w.Body.String())
+	}
+
+	// Only the first decision of the synced policy has been synced, due to
+	// the long sync interval, and the rest will be flushed on stop.
+	s.stop()
+	start := time.Now().Truncate(time.Hour).UnixNano()
+	if count, _ := store.Get("synced:alice", start); count != 7 {
+		t.Errorf("store.Get() = %d, want: %d", count, 7)
+	}
+}
+
+func TestServer_Validate(t *testing.T) {
+	s := newServer(config.NewSet(nil))
+	cases := []struct {
+		policies string
+		ok       bool
+	}{
+		{`{"a": {"rate": "2/1m", "key": "{user}"}}`, true},
+		{`{"a": {"rate": "2/1m", "key": "{user"}}`, false},
+		{`{"a": {"rate": "2/1m", "key": "{user}", "backend": "unknown", "sync_interval": "1s"}}`, false},
+	}
+	for _, c := range cases {
+		var f config.File
+		if err := json.Unmarshal([]byte(`{"policies": `+c.policies+`}`), &f); err != nil {
+			t.Fatalf("%s: err: %v", c.policies, err)
+		}
+		if _, err := s.validate(&f); (err == nil) != c.ok {
+			t.Errorf("%s: got err %v, want ok: %v", c.policies, err, c.ok)
+		}
+	}
+	if names := s.set.Names(); len(names) != 0 {
+		t.Errorf("s.set.Names() = %v, want: none", names)
+	}
+}
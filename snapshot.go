@@ -0,0 +1,125 @@
+package slidingwindow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// snapshotVersion is the version of the format written by KeyedLimiter.Snapshot.
+const snapshotVersion = 1
+
+// State represents the serializable state of a limiter.
+type State struct {
+	Size  time.Duration `json:"size"`
+	Limit int64         `json:"limit"`
+
+	// The start boundaries (timestamps in nanoseconds) and the counts
+	// of the current and the previous windows.
+	CurrStart int64 `json:"curr_start"`
+	CurrCount int64 `json:"curr_count"`
+	PrevStart int64 `json:"prev_start"`
+	PrevCount int64 `json:"prev_count"`
+}
+
+// Snapshot returns the current state of the limiter.
+func (lim *Limiter) Snapshot() State {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	return State{
+		Size:      lim.currSize(),
+		Limit:     lim.Limit(),
+		CurrStart: lim.curr.Start().UnixNano(),
+		CurrCount: lim.curr.Count(),
+		PrevStart: lim.prev.Start().UnixNano(),
+		PrevCount: lim.prev.Count(),
+	}
+}
+
+// Restore sets the limit and the windows of the limiter according to s,
+// while the boundary policy of the limiter is kept unchanged.
+//
+// Note that for SyncWindow, the restored count will be overwritten by
+// the one from the central datastore after the next synchronization.
+func (lim *Limiter) Restore(s State) {
+	lim.SetLimit(s.Limit)
+	lim.restoreWindows(s)
+}
+
+// restoreWindows sets the windows of the limiter according to s, while
+// the limit and the boundary policy of the limiter are kept unchanged.
+func (lim *Limiter) restoreWindows(s State) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	lim.curr.Reset(time.Unix(0, s.CurrStart), s.CurrCount)
+	lim.prev.Reset(time.Unix(0, s.PrevStart), s.PrevCount)
+	lim.publish()
+}
+
+// RestoreLimiter creates a new limiter from the state s, and returns
+// a function to stop the possible sync behaviour within the current window.
+//
+// Since the boundary policy is not a part of the state, the new limiter
+// always has fixed-size windows as NewLimiter does. For the limiters with
+// other policies (e.g. Offset or Daily), use RestoreBoundaryLimiter.
+func RestoreLimiter(s State, newWindow NewWindow) (*Limiter, StopFunc) {
+	return RestoreBoundaryLimiter(s, Every(s.Size), newWindow)
+}
+
+// RestoreBoundaryLimiter creates a new limiter, whose windows are aligned by
+// the given boundary policy, from the state s, and returns a function to stop
+// the possible sync behaviour within the current window.
+func RestoreBoundaryLimiter(s State, boundary Boundary, newWindow NewWindow) (*Limiter, StopFunc) {
+	lim, stop := NewBoundaryLimiter(boundary, s.Limit, newWindow)
+	lim.restoreWindows(s)
+	return lim, stop
+}
+
+// keyedSnapshot is the serialization format of KeyedLimiter.
+type keyedSnapshot struct {
+	Version  int              `json:"version"`
+	Limiters map[string]State `json:"limiters"`
+}
+
+// Snapshot writes the states of all the limiters to w, in a versioned
+// JSON format which can be read by Restore.
+func (k *KeyedLimiter) Snapshot(w io.Writer) error {
+	snapshot := keyedSnapshot{
+		Version:  snapshotVersion,
+		Limiters: make(map[string]State),
+	}
+	for _, key := range k.Keys() {
+		if lim := k.get(key); lim != nil {
+			snapshot.Limiters[key] = lim.Snapshot()
+		}
+	}
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// Restore reads the states written by Snapshot from r, and restores the
+// windows of the limiters accordingly. The limits are not restored, so the
+// limit and the overrides of k stay in effect.
+//
+// States whose window size differs from the one of k are skipped, since
+// their windows can not be aligned with the windows of k.
+func (k *KeyedLimiter) Restore(r io.Reader) error {
+	var snapshot keyedSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("slidingwindow: unsupported snapshot version %d", snapshot.Version)
+	}
+
+	size := k.Size()
+	for key, s := range snapshot.Limiters {
+		if s.Size != size {
+			continue
+		}
+		k.Limiter(key).restoreWindows(s)
+	}
+	return nil
+}
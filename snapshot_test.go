@@ -0,0 +1,121 @@
+package slidingwindow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Snapshot_Restore(t *testing.T) {
+	newWindow := func() (Window, StopFunc) {
+		return NewLocalWindow()
+	}
+
+	lim, _ := NewLimiter(size, limit, newWindow)
+	lim.AllowN(t0, 6)
+	lim.AllowN(t10, 2)
+
+	want := State{
+		Size:      size,
+		Limit:     limit,
+		CurrStart: t10.UnixNano(),
+		CurrCount: 2,
+		PrevStart: t0.UnixNano(),
+		PrevCount: 6,
+	}
+	got := lim.Snapshot()
+	if got != want {
+		t.Fatalf("lim.Snapshot() = %+v, want: %+v", got, want)
+	}
+
+	restored, _ := RestoreLimiter(got, newWindow)
+
+	cases := []caseArg{
+		// prev-window: [t0, t0 + 1s), count: 6
+		// curr-window: [t10, t10 + 1s), count: 2
+		{t12, 5, false}, // count will be (4/5*6 + 2 + 5) ≈ 11, so it fails
+		{t15, 5, true},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			ok := restored.AllowN(c.t, c.n)
+			if ok != c.ok {
+				t.Errorf("restored.AllowN(%v, %v) = %v, want: %v",
+					c.t, c.n, ok, c.ok)
+			}
+		})
+	}
+}
+
+func TestRestoreBoundaryLimiter(t *testing.T) {
+	boundary := Offset(Every(size), 3*d)
+	lim, _ := NewBoundaryLimiter(boundary, limit, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+	lim.AllowN(t0, 6)
+
+	restored, _ := RestoreBoundaryLimiter(lim.Snapshot(), boundary, func() (Window, StopFunc) {
+		return NewLocalWindow()
+	})
+	if got, want := restored.Snapshot(), lim.Snapshot(); got != want {
+		t.Errorf("restored.Snapshot() = %+v, want: %+v", got, want)
+	}
+	// The window of the 6 events is [t0 - 7d, t3), which barely overlaps
+	// with the sliding window ending at t12.
+	if ok := restored.AllowN(t12, 10); !ok {
+		t.Errorf("restored.AllowN(t12, 10) = false, want: true")
+	}
+}
+
+func TestKeyedLimiter_Snapshot_Restore(t *testing.T) {
+	newWindow := func(key string) (Window, StopFunc) {
+		return NewLocalWindow()
+	}
+
+	k := NewKeyedLimiter(size, limit, newWindow)
+	k.AllowN("a", t0, 10)
+	k.AllowN("b", t0, 4)
+
+	var buf bytes.Buffer
+	if err := k.Snapshot(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	restored := NewKeyedLimiter(size, limit, newWindow)
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		got, want := restored.Limiter(key).Snapshot(), k.Limiter(key).Snapshot()
+		if got != want {
+			t.Errorf("Snapshot of %q = %+v, want: %+v", key, got, want)
+		}
+	}
+
+	// The limits are not restored, so a temporary override (of "a") is not
+	// carried over to another keyed limiter with a different limit.
+	k.Override("a", 2*limit, time.Hour)
+	defer k.Stop()
+	buf.Reset()
+	if err := k.Snapshot(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	restored = NewKeyedLimiter(size, limit/2, newWindow)
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if got := restored.Limiter(key).Limit(); got != limit/2 {
+			t.Errorf("Limit of %q = %d, want: %d", key, got, limit/2)
+		}
+	}
+
+	// Snapshots with an unknown version are rejected.
+	err := restored.Restore(strings.NewReader(`{"version": 0}`))
+	if want := "slidingwindow: unsupported snapshot version 0"; err == nil || err.Error() != want {
+		t.Errorf("restored.Restore() err = %v, want: %s", err, want)
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/RussellLuo/slidingwindow/swctl
+
+go 1.13
+
+require (
+	github.com/RussellLuo/slidingwindow v0.0.0-00010101000000-000000000000
+	github.com/RussellLuo/slidingwindow/redisstore v0.0.0-00010101000000-000000000000
+	github.com/go-redis/redis v6.15.9+incompatible
+)
+
+replace github.com/RussellLuo/slidingwindow => ../
+
+replace github.com/RussellLuo/slidingwindow/redisstore => ../redisstore
@@ -0,0 +1,253 @@
+// Command swctl inspects and edits the counters kept in a central datastore,
+// whose windows are identified by "key@start" (start is the start boundary
+// of the window in Unix nanoseconds).
+//
+// Usage:
+//
+//	swctl [flags] list [PREFIX]                  list the windows of the keys with PREFIX
+//	swctl [flags] estimate -size D -limit N KEY  show the current sliding count of KEY
+//	swctl [flags] delete KEY [START]             delete a window of KEY, or all of them
+//	swctl [flags] adjust KEY START DELTA         add DELTA to a window of KEY
+//
+// START is either in Unix nanoseconds or in RFC 3339 (e.g.
+// "2020-09-13T12:26:40Z"). The datastore is specified by -store:
+//
+//	redis://localhost:6379     a Redis server (see package redisstore)
+//	file:counts.json           a local file (see package filestore)
+//	http://host:8080/store     a remote httpstore.Handler
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/filestore"
+	"github.com/RussellLuo/slidingwindow/httpstore"
+	"github.com/RussellLuo/slidingwindow/redisstore"
+	"github.com/go-redis/redis"
+)
+
+var errUsage = errors.New("usage: swctl [flags] list|estimate|delete|adjust ...")
+
+func main() {
+	var (
+		storeFlag = flag.String("store", "redis://localhost:6379", "The datastore, i.e. redis://ADDR, file:PATH or http(s)://URL.")
+		ttlFlag   = flag.Duration("ttl", 24*time.Hour, "The expiration of the Redis keys changed by adjust.")
+		utcFlag   = flag.Bool("utc", false, "Show the times in UTC instead of the local time zone.")
+	)
+	flag.Parse()
+
+	store, closeStore, err := openStore(*storeFlag, *ttlFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer closeStore()
+
+	c := &cmd{store: store, out: os.Stdout, now: time.Now, loc: time.Local}
+	if *utcFlag {
+		c.loc = time.UTC
+	}
+	if err := c.run(flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		closeStore()
+		os.Exit(2)
+	}
+}
+
+// openStore opens the datastore specified by spec.
+func openStore(spec string, ttl time.Duration) (sw.ListDatastore, func() error, error) {
+	noop := func() error { return nil }
+	switch {
+	case strings.HasPrefix(spec, "redis://"):
+		client := redis.NewClient(&redis.Options{Addr: strings.TrimPrefix(spec, "redis://")})
+		return redisstore.NewRedisDatastore(client, ttl), client.Close, nil
+	case strings.HasPrefix(spec, "file:"):
+		store, err := filestore.Open(strings.TrimPrefix(spec, "file:"))
+		return store, noop, err
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return httpstore.NewClient(spec, nil), noop, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown store %q", spec)
+	}
+}
+
+// cmd runs the subcommands against a datastore.
+type cmd struct {
+	store sw.ListDatastore
+	out   io.Writer
+	now   func() time.Time
+	loc   *time.Location
+}
+
+func (c *cmd) run(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list":
+		return c.list(args[1:])
+	case "estimate":
+		return c.estimate(args[1:])
+	case "delete":
+		return c.delete(args[1:])
+	case "adjust":
+		return c.adjust(args[1:])
+	default:
+		return errUsage
+	}
+}
+
+func (c *cmd) list(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: swctl list [PREFIX]")
+	}
+	var prefix string
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+
+	counts, err := c.store.List(prefix)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSTART\tSTART(ns)\tCOUNT")
+	for _, wc := range counts {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", wc.Key, c.formatTime(wc.Start), wc.Start, wc.Count)
+	}
+	return w.Flush()
+}
+
+// estimate computes the count of the sliding window ending now, in the same
+// way as Limiter does. Only the windows aligned to the Unix epoch (i.e. the
+// default boundary policy) are supported.
+func (c *cmd) estimate(args []string) error {
+	fs := flag.NewFlagSet("estimate", flag.ContinueOnError)
+	fs.SetOutput(c.out)
+	size := fs.Duration("size", time.Second, "The size of the windows.")
+	limit := fs.Int64("limit", 0, "The maximum events permitted. Zero means not shown.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *size <= 0 {
+		return errors.New("usage: swctl estimate -size D [-limit N] KEY")
+	}
+	key := fs.Arg(0)
+
+	now := c.now()
+	currStart := now.Truncate(*size)
+	prevStart := currStart.Add(-*size)
+
+	curr, err := c.store.Get(key, currStart.UnixNano())
+	if err != nil {
+		return err
+	}
+	prev, err := c.store.Get(key, prevStart.UnixNano())
+	if err != nil {
+		return err
+	}
+
+	elapsed := now.Sub(currStart)
+	weight := float64(*size-elapsed) / float64(*size)
+	count := int64(weight*float64(prev)) + curr
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "key:\t%s\n", key)
+	fmt.Fprintf(w, "now:\t%s\n", now.In(c.loc).Format(time.RFC3339Nano))
+	fmt.Fprintf(w, "prev:\t%d @ %s (weight %.3f)\n", prev, c.formatTime(prevStart.UnixNano()), weight)
+	fmt.Fprintf(w, "curr:\t%d @ %s\n", curr, c.formatTime(currStart.UnixNano()))
+	fmt.Fprintf(w, "count:\t%d\n", count)
+	if *limit > 0 {
+		remaining := *limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		fmt.Fprintf(w, "limit:\t%d\n", *limit)
+		fmt.Fprintf(w, "remaining:\t%d\n", remaining)
+	}
+	return w.Flush()
+}
+
+func (c *cmd) delete(args []string) error {
+	switch len(args) {
+	case 1:
+		// Delete all the windows of the key, but not those of the other
+		// keys sharing the same prefix.
+		counts, err := c.store.List(args[0])
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, wc := range counts {
+			if wc.Key != args[0] {
+				continue
+			}
+			if err := c.store.Delete(wc.Key, wc.Start); err != nil {
+				return err
+			}
+			n++
+		}
+		fmt.Fprintf(c.out, "deleted %d window(s)\n", n)
+		return nil
+	case 2:
+		start, err := parseStart(args[1])
+		if err != nil {
+			return err
+		}
+		if err := c.store.Delete(args[0], start); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "deleted 1 window(s)")
+		return nil
+	default:
+		return errors.New("usage: swctl delete KEY [START]")
+	}
+}
+
+func (c *cmd) adjust(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: swctl adjust KEY START DELTA")
+	}
+	start, err := parseStart(args[1])
+	if err != nil {
+		return err
+	}
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad delta %q", args[2])
+	}
+
+	count, err := c.store.Add(args[0], start, delta)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "%s@%d: %d\n", args[0], start, count)
+	return nil
+}
+
+func (c *cmd) formatTime(ns int64) string {
+	return time.Unix(0, ns).In(c.loc).Format(time.RFC3339Nano)
+}
+
+// parseStart parses the start boundary of a window, which is either in
+// Unix nanoseconds or in RFC 3339.
+func parseStart(s string) (int64, error) {
+	if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ns, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("bad start %q: neither Unix nanoseconds nor RFC 3339", s)
+	}
+	return t.UnixNano(), nil
+}
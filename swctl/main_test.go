@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RussellLuo/slidingwindow/httpstore"
+)
+
+func TestCmd(t *testing.T) {
+	store := httpstore.NewMemStore()
+	now := time.Unix(1600000000, 0).Add(750 * time.Millisecond) // 3/4 into the window.
+	sec := int64(time.Second)
+	curr := now.Truncate(time.Second).UnixNano()
+
+	store.Add("a", curr-sec, 8) // nolint:errcheck
+	store.Add("a", curr, 3)     // nolint:errcheck
+	store.Add("ab", curr, 1)    // nolint:errcheck
+
+	var out bytes.Buffer
+	c := &cmd{store: store, out: &out, now: func() time.Time { return now }, loc: time.UTC}
+
+	cases := []struct {
+		args []string
+		want []string // The substrings expected in the output.
+	}{
+		{
+			[]string{"list", "a"},
+			[]string{"a    2020-09-13T12:26:39Z  1599999999000000000  8", "ab   2020-09-13T12:26:40Z  1600000000000000000  1"},
+		},
+		{
+			[]string{"estimate", "-size", "1s", "-limit", "10", "a"},
+			[]string{"count:      5", "remaining:  5"}, // 8*0.25 + 3
+		},
+		{
+			[]string{"adjust", "a", "2020-09-13T12:26:40Z", "4"},
+			[]string{"a@1600000000000000000: 7"},
+		},
+		{
+			[]string{"delete", "a"},
+			[]string{"deleted 2 window(s)"},
+		},
+		{
+			[]string{"list"},
+			[]string{"ab"},
+		},
+	}
+	for _, cs := range cases {
+		out.Reset()
+		if err := c.run(cs.args); err != nil {
+			t.Fatalf("%v: err: %v", cs.args, err)
+		}
+		for _, want := range cs.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%v: got %q, want to contain: %q", cs.args, out.String(), want)
+			}
+		}
+	}
+
+	if got, _ := store.Get("a", curr); got != 0 {
+		t.Errorf("Get after delete: got %d, want: 0", got)
+	}
+
+	for _, args := range [][]string{nil, {"mul"}, {"adjust", "a", "yesterday", "1"}} {
+		if err := c.run(args); err == nil {
+			t.Errorf("%v: got nil err", args)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/RussellLuo/slidingwindow/swreplay
+
+go 1.13
+
+require (
+	github.com/RussellLuo/slidingwindow v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/RussellLuo/slidingwindow => ../
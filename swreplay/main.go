@@ -0,0 +1,144 @@
+// Command swreplay replays recorded request traces through a policy, by
+// calling AllowN with the recorded timestamps, so that a new limit can be
+// evaluated against the real traffic before it's rolled out:
+//
+//	swreplay -rate 100/1m -timeline timeline.csv access.log
+//	swreplay -config slidingwindowd.json -policy api trace.jsonl
+//
+// The supported formats of traces are:
+//
+//	csv    timestamp,key[,cost] (with an optional header row)
+//	jsonl  {"timestamp": ..., "key": "...", "cost": 1} (cost is optional)
+//	nginx  the combined log format, whose keys are built by -key
+//
+// where a timestamp is either in RFC 3339, or the Unix time in seconds
+// (e.g. 1600000000.123). The policy is always evaluated with local windows,
+// i.e. as if there were only one node, regardless of its backend.
+//
+// The summary stats and the top denied keys are written to the standard
+// output, and the per-key timelines of the allowed and denied requests are
+// written to the file specified by -timeline in CSV.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/config"
+)
+
+func main() {
+	var (
+		rateFlag     = flag.String("rate", "", `The policy in the shorthand form, e.g. "100/1m".`)
+		configFlag   = flag.String("config", "", "The config file containing the policy, as an alternative to -rate.")
+		policyFlag   = flag.String("policy", "", "The name of the policy in the config file.")
+		formatFlag   = flag.String("format", formatAuto, "The format of the traces, i.e. auto, csv, jsonl or nginx.")
+		keyFlag      = flag.String("key", "{remote_addr}", "The key template of the nginx logs, from {remote_addr}, {remote_user}, {method}, {path}, {status}, {http_referer} and {http_user_agent}.")
+		bucketFlag   = flag.Duration("bucket", 0, "The period of the timelines. Zero means the window size of the policy.")
+		timelineFlag = flag.String("timeline", "", `The file to write the timelines to. "-" means the standard output.`)
+		topFlag      = flag.Int("top", 10, "The number of the top denied keys to show.")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [TRACE...]\n\nThe traces are read from the standard input if none is given.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*rateFlag, *configFlag, *policyFlag, *formatFlag, *keyFlag, *bucketFlag, *timelineFlag, *topFlag, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(rate, configPath, policyName, format, key string, bucket time.Duration, timeline string, top int, traces []string) error {
+	p, err := loadPolicy(rate, configPath, policyName)
+	if err != nil {
+		return err
+	}
+	if bucket <= 0 {
+		bucket = p.Size
+	}
+
+	lim := sw.NewKeyedLimiter(p.Size, p.Limit, p.NewKeyedWindow(policyName, nil))
+	defer lim.Stop()
+	r := newReplayer(lim, bucket)
+
+	if len(traces) == 0 {
+		traces = []string{"-"}
+	}
+	for _, path := range traces {
+		if err := replayFile(r, path, format, key); err != nil {
+			return err
+		}
+	}
+
+	if err := r.writeSummary(os.Stdout, top); err != nil {
+		return err
+	}
+	if timeline == "" {
+		return nil
+	}
+	if timeline == "-" {
+		fmt.Println()
+		return r.writeTimelines(os.Stdout)
+	}
+	f, err := os.Create(timeline)
+	if err != nil {
+		return err
+	}
+	if err := r.writeTimelines(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadPolicy loads the policy either from rate, or from the config file.
+func loadPolicy(rate, configPath, policyName string) (config.Policy, error) {
+	switch {
+	case rate != "" && configPath != "":
+		return config.Policy{}, errors.New("-rate conflicts with -config")
+	case rate != "":
+		limit, size, err := config.ParseRate(rate)
+		return config.Policy{Limit: limit, Size: size}, err
+	case configPath != "":
+		f, err := config.Load(configPath)
+		if err != nil {
+			return config.Policy{}, err
+		}
+		p, ok := f.Policies[policyName]
+		if !ok {
+			return config.Policy{}, fmt.Errorf("policy %q not found in %s", policyName, configPath)
+		}
+		return p, nil
+	default:
+		return config.Policy{}, errors.New("either -rate or -config is required")
+	}
+}
+
+// replayFile replays the trace at path, where "-" means the standard input.
+func replayFile(r *replayer, path, format, key string) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	tr, err := newTraceReader(in, format, key)
+	if err != nil {
+		return err
+	}
+	if err := r.replay(tr); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+func readAll(t *testing.T, trace, format, key string) []record {
+	tr, err := newTraceReader(strings.NewReader(trace), format, key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var records []record
+	for {
+		rec, err := tr.next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestTraceReader(t *testing.T) {
+	t0 := time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)
+
+	cases := []struct {
+		name  string
+		trace string
+		key   string
+		want  []record
+	}{
+		{
+			name:  "csv",
+			trace: "timestamp,key,cost\n2020-09-13T12:26:40Z,a,2\n1600000000.5,b\n",
+			want: []record{
+				{t0, "a", 2},
+				{t0.Add(500 * time.Millisecond), "b", 1},
+			},
+		},
+		{
+			name:  "jsonl",
+			trace: `{"timestamp":"2020-09-13T12:26:40Z","key":"a","cost":3}` + "\n\n" + `{"timestamp":1600000001,"key":"b"}` + "\n",
+			want: []record{
+				{t0, "a", 3},
+				{t0.Add(time.Second), "b", 1},
+			},
+		},
+		{
+			name:  "nginx",
+			trace: `10.0.0.1 - alice [13/Sep/2020:12:26:40 +0000] "GET /api/v1/users?page=2 HTTP/1.1" 200 612 "-" "curl/7.64.1"` + "\n",
+			key:   "{remote_user}:{method}:{path}",
+			want: []record{
+				{t0, "alice:GET:/api/v1/users", 1},
+			},
+		},
+	}
+	for _, c := range cases {
+		got := readAll(t, c.trace, formatAuto, c.key)
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: got %v, want: %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if !got[i].time.Equal(c.want[i].time) || got[i].key != c.want[i].key || got[i].cost != c.want[i].cost {
+				t.Errorf("%s: #%d: got %v, want: %v", c.name, i, got[i], c.want[i])
+			}
+		}
+	}
+
+	tr, _ := newTraceReader(strings.NewReader("yesterday,a\n"), formatCSV, "")
+	if _, err := tr.next(); err == nil {
+		t.Errorf("bad timestamp: got nil err")
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	lim := sw.NewKeyedLimiter(time.Second, 2, func(key string) (sw.Window, sw.StopFunc) {
+		return sw.NewLocalWindow()
+	})
+	defer lim.Stop()
+	r := newReplayer(lim, time.Second)
+
+	trace := strings.Join([]string{
+		"1600000000.0,a",
+		"1600000000.1,a",
+		"1600000000.2,a", // Denied.
+		"1600000000.1,b", // Out of order.
+		"1600000002.0,a",
+		"1600000002.1,a,5", // Denied.
+	}, "\n")
+	tr, _ := newTraceReader(strings.NewReader(trace), formatCSV, "")
+	if err := r.replay(tr); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var summary bytes.Buffer
+	if err := r.writeSummary(&summary, 10); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, want := range []string{
+		"requests:      6",
+		"denied:        2 (33.33%)",
+		"keys denied:   1 (50.00%)",
+		"out of order:  1",
+		"a    5         3        2       40.00    6",
+	} {
+		if !strings.Contains(summary.String(), want) {
+			t.Errorf("summary: got %q, want to contain: %q", summary.String(), want)
+		}
+	}
+
+	var timelines bytes.Buffer
+	if err := r.writeTimelines(&timelines); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := []string{
+		"key,start,allowed,denied",
+		"a,2020-09-13T12:26:40Z,2,1",
+		"a,2020-09-13T12:26:42Z,1,1",
+		"b,2020-09-13T12:26:40Z,1,0",
+	}
+	if got := strings.Split(strings.TrimSpace(timelines.String()), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("timelines: got %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// bucket is the decisions during a period of a timeline.
+type bucket struct {
+	allowed, denied int64
+}
+
+// keyStats is the decisions of a key.
+type keyStats struct {
+	key             string
+	allowed, denied int64 // The number of requests.
+	allowedCost     int64
+	deniedCost      int64
+	timeline        map[int64]*bucket // Keyed by the start of the period.
+}
+
+// replayer replays the records through a keyed limiter.
+type replayer struct {
+	lim    *sw.KeyedLimiter
+	bucket time.Duration // The period of the timelines.
+
+	first, last time.Time
+	records     int64
+	outOfOrder  int64 // The records earlier than the previous one.
+	keys        map[string]*keyStats
+}
+
+func newReplayer(lim *sw.KeyedLimiter, bucket time.Duration) *replayer {
+	return &replayer{lim: lim, bucket: bucket, keys: make(map[string]*keyStats)}
+}
+
+// replay replays all the records read from tr. Since the limiters can not go
+// back in time, a record earlier than the previous one is replayed as if it
+// happened at the time of the previous one.
+func (r *replayer) replay(tr traceReader) error {
+	for {
+		rec, err := tr.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r.add(rec)
+	}
+}
+
+func (r *replayer) add(rec record) {
+	if r.records == 0 {
+		r.first = rec.time
+	}
+	if rec.time.Before(r.last) {
+		r.outOfOrder++
+		rec.time = r.last
+	}
+	r.last = rec.time
+	r.records++
+
+	ks, ok := r.keys[rec.key]
+	if !ok {
+		ks = &keyStats{key: rec.key, timeline: make(map[int64]*bucket)}
+		r.keys[rec.key] = ks
+	}
+	start := rec.time.Truncate(r.bucket).UnixNano()
+	b, ok := ks.timeline[start]
+	if !ok {
+		b = &bucket{}
+		ks.timeline[start] = b
+	}
+
+	if r.lim.AllowN(rec.key, rec.time, rec.cost) {
+		ks.allowed++
+		ks.allowedCost += rec.cost
+		b.allowed++
+	} else {
+		ks.denied++
+		ks.deniedCost += rec.cost
+		b.denied++
+	}
+}
+
+// writeSummary writes the summary stats, along with the top keys by the
+// number of denied requests, to w.
+func (r *replayer) writeSummary(w io.Writer, top int) error {
+	var allowed, denied, deniedKeys int64
+	stats := make([]*keyStats, 0, len(r.keys))
+	for _, ks := range r.keys {
+		allowed += ks.allowed
+		denied += ks.denied
+		if ks.denied > 0 {
+			deniedKeys++
+		}
+		stats = append(stats, ks)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].denied != stats[j].denied {
+			return stats[i].denied > stats[j].denied
+		}
+		return stats[i].key < stats[j].key
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "policy:\t%d per %s\n", r.lim.Limit(), r.lim.Size())
+	if r.records > 0 {
+		fmt.Fprintf(tw, "period:\t%s - %s (%s)\n", r.first.Format(time.RFC3339), r.last.Format(time.RFC3339), r.last.Sub(r.first))
+	}
+	fmt.Fprintf(tw, "requests:\t%d\n", r.records)
+	fmt.Fprintf(tw, "allowed:\t%d (%.2f%%)\n", allowed, percent(allowed, r.records))
+	fmt.Fprintf(tw, "denied:\t%d (%.2f%%)\n", denied, percent(denied, r.records))
+	fmt.Fprintf(tw, "keys:\t%d\n", len(r.keys))
+	fmt.Fprintf(tw, "keys denied:\t%d (%.2f%%)\n", deniedKeys, percent(deniedKeys, int64(len(r.keys))))
+	if r.outOfOrder > 0 {
+		fmt.Fprintf(tw, "out of order:\t%d\n", r.outOfOrder)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if top <= 0 || deniedKeys == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tREQUESTS\tALLOWED\tDENIED\tDENIED%\tDENIED COST")
+	for i, ks := range stats {
+		if i >= top || ks.denied == 0 {
+			break
+		}
+		n := ks.allowed + ks.denied
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f\t%d\n", ks.key, n, ks.allowed, ks.denied, percent(ks.denied, n), ks.deniedCost)
+	}
+	return tw.Flush()
+}
+
+// writeTimelines writes the timelines of all the keys to w in CSV, whose
+// rows are sorted by key and then by time.
+func (r *replayer) writeTimelines(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"key", "start", "allowed", "denied"}) // nolint:errcheck
+
+	keys := make([]string, 0, len(r.keys))
+	for key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ks := r.keys[key]
+		starts := make([]int64, 0, len(ks.timeline))
+		for start := range ks.timeline {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+		for _, start := range starts {
+			b := ks.timeline[start]
+			cw.Write([]string{ // nolint:errcheck
+				key,
+				time.Unix(0, start).UTC().Format(time.RFC3339Nano),
+				strconv.FormatInt(b.allowed, 10),
+				strconv.FormatInt(b.denied, 10),
+			})
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func percent(n, of int64) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
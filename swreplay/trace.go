@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The formats of traces.
+const (
+	formatAuto  = "auto"
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+	formatNginx = "nginx"
+)
+
+// record is a recorded request.
+type record struct {
+	time time.Time
+	key  string
+	cost int64
+}
+
+// traceReader reads records from a trace, until io.EOF.
+type traceReader interface {
+	next() (record, error)
+}
+
+// newTraceReader creates a reader of the trace in the given format. The
+// keys of the nginx logs are built by keyTemplate.
+func newTraceReader(r io.Reader, format, keyTemplate string) (traceReader, error) {
+	br := bufio.NewReader(r)
+	if format == formatAuto {
+		format = detectFormat(br)
+	}
+
+	switch format {
+	case formatCSV:
+		cr := csv.NewReader(br)
+		cr.FieldsPerRecord = -1
+		cr.ReuseRecord = true
+		return &csvReader{r: cr}, nil
+	case formatJSONL:
+		return &jsonlReader{s: newScanner(br)}, nil
+	case formatNginx:
+		return &nginxReader{s: newScanner(br), key: keyTemplate}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// detectFormat detects the format by the first line of the trace.
+func detectFormat(br *bufio.Reader) string {
+	line, _ := br.Peek(4096)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	line = bytes.TrimSpace(line)
+
+	switch {
+	case bytes.HasPrefix(line, []byte("{")):
+		return formatJSONL
+	case nginxPattern.Match(line):
+		return formatNginx
+	default:
+		return formatCSV
+	}
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return s
+}
+
+// parseTime parses a timestamp, which is either in RFC 3339, or the Unix
+// time in seconds (with the optional fraction, e.g. "1600000000.123").
+func parseTime(s string) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad timestamp %q", s)
+	}
+	return t, nil
+}
+
+// parseCost parses the cost, which defaults to 1 if s is empty.
+func parseCost(s string) (int64, error) {
+	if s == "" {
+		return 1, nil
+	}
+	cost, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || cost < 0 {
+		return 0, fmt.Errorf("bad cost %q", s)
+	}
+	return cost, nil
+}
+
+// csvReader reads the CSV trace, whose columns are timestamp, key and the
+// optional cost. The header row, if any, is skipped.
+type csvReader struct {
+	r   *csv.Reader
+	row int
+}
+
+func (c *csvReader) next() (record, error) {
+	for {
+		fields, err := c.r.Read()
+		if err != nil {
+			return record{}, err
+		}
+		c.row++
+
+		if c.row == 1 && len(fields) > 0 && strings.EqualFold(strings.TrimSpace(fields[0]), "timestamp") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return record{}, fmt.Errorf("row %d: want 2 or 3 fields, got %d", c.row, len(fields))
+		}
+
+		var rec record
+		if rec.time, err = parseTime(strings.TrimSpace(fields[0])); err != nil {
+			return record{}, fmt.Errorf("row %d: %v", c.row, err)
+		}
+		rec.key = fields[1]
+		var cost string
+		if len(fields) == 3 {
+			cost = strings.TrimSpace(fields[2])
+		}
+		if rec.cost, err = parseCost(cost); err != nil {
+			return record{}, fmt.Errorf("row %d: %v", c.row, err)
+		}
+		return rec, nil
+	}
+}
+
+// jsonlReader reads the JSONL trace, whose objects have the fields
+// timestamp (a string or a number), key and the optional cost.
+type jsonlReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (j *jsonlReader) next() (record, error) {
+	for j.s.Scan() {
+		j.line++
+		line := bytes.TrimSpace(j.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var v struct {
+			Timestamp json.RawMessage `json:"timestamp"`
+			Key       string          `json:"key"`
+			Cost      *int64          `json:"cost"`
+		}
+		if err := json.Unmarshal(line, &v); err != nil {
+			return record{}, fmt.Errorf("line %d: %v", j.line, err)
+		}
+
+		ts := string(v.Timestamp)
+		if s, err := strconv.Unquote(ts); err == nil {
+			ts = s
+		}
+		t, err := parseTime(ts)
+		if err != nil {
+			return record{}, fmt.Errorf("line %d: %v", j.line, err)
+		}
+
+		rec := record{time: t, key: v.Key, cost: 1}
+		if v.Cost != nil {
+			if *v.Cost < 0 {
+				return record{}, fmt.Errorf("line %d: bad cost %d", j.line, *v.Cost)
+			}
+			rec.cost = *v.Cost
+		}
+		return rec, nil
+	}
+	if err := j.s.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+// nginxPattern matches a line of the nginx combined log format:
+//
+//	$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"
+var nginxPattern = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) \S+ "([^"]*)" "([^"]*)"`)
+
+// nginxTimeLayout is the layout of $time_local.
+const nginxTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// nginxReader reads the nginx logs in the combined format, whose every
+// request costs 1.
+type nginxReader struct {
+	s    *bufio.Scanner
+	key  string // The key template, e.g. "{remote_addr}".
+	line int
+}
+
+func (n *nginxReader) next() (record, error) {
+	for n.s.Scan() {
+		n.line++
+		line := n.s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		m := nginxPattern.FindStringSubmatch(line)
+		if m == nil {
+			return record{}, fmt.Errorf("line %d: not in the combined log format", n.line)
+		}
+		t, err := time.Parse(nginxTimeLayout, m[3])
+		if err != nil {
+			return record{}, fmt.Errorf("line %d: bad time %q", n.line, m[3])
+		}
+
+		var method, path string
+		if parts := strings.Fields(m[4]); len(parts) >= 2 {
+			method, path = parts[0], parts[1]
+			if i := strings.IndexByte(path, '?'); i >= 0 {
+				path = path[:i]
+			}
+		}
+		key := strings.NewReplacer(
+			"{remote_addr}", m[1],
+			"{remote_user}", m[2],
+			"{method}", method,
+			"{path}", path,
+			"{status}", m[5],
+			"{http_referer}", m[6],
+			"{http_user_agent}", m[7],
+		).Replace(n.key)
+
+		return record{time: t, key: key, cost: 1}, nil
+	}
+	if err := n.s.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
@@ -1,7 +1,9 @@
 package slidingwindow
 
 import (
+	"errors"
 	"log"
+	"sort"
 	"time"
 )
 
@@ -15,6 +17,38 @@ type Datastore interface {
 	Get(key string, start int64) (int64, error)
 }
 
+// WindowCount is the count of the window represented by start, of the
+// given key, in a central datastore.
+type WindowCount struct {
+	Key   string `json:"key"`
+	Start int64  `json:"start"`
+	Count int64  `json:"count"`
+}
+
+// ListDatastore represents a central datastore that additionally supports
+// enumerating and deleting windows, which is required by the tools that
+// inspect the datastore (e.g. swctl).
+type ListDatastore interface {
+	Datastore
+
+	// List returns the windows whose keys have the given prefix, sorted
+	// by key and then by start.
+	List(prefix string) ([]WindowCount, error)
+
+	// Delete deletes the window represented by start.
+	Delete(key string, start int64) error
+}
+
+// SortWindowCounts sorts counts by key and then by start.
+func SortWindowCounts(counts []WindowCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Key != counts[j].Key {
+			return counts[i].Key < counts[j].Key
+		}
+		return counts[i].Start < counts[j].Start
+	})
+}
+
 // syncHelper is a helper that will be leveraged by both BlockingSynchronizer
 // and NonblockingSynchronizer.
 type syncHelper struct {
@@ -50,7 +84,8 @@ func (h *syncHelper) End() {
 func (h *syncHelper) Sync(req SyncRequest) (resp SyncResponse, err error) {
 	var newCount int64
 
-	if req.Changes > 0 {
+	// Note that changes may be negative, if some counts have been refunded.
+	if req.Changes != 0 {
 		newCount, err = h.store.Add(req.Key, req.Start, req.Changes)
 	} else {
 		newCount, err = h.store.Get(req.Key, req.Start)
@@ -68,6 +103,48 @@ func (h *syncHelper) Sync(req SyncRequest) (resp SyncResponse, err error) {
 	}, nil
 }
 
+// Clear clears the counts of the windows of key represented by starts, by
+// deleting the windows if the datastore supports it, or by adding the
+// negated counts otherwise.
+func (h *syncHelper) Clear(key string, starts ...int64) error {
+	ls, canDelete := h.store.(ListDatastore)
+	for _, start := range starts {
+		if canDelete {
+			if err := ls.Delete(key, start); err != nil {
+				return err
+			}
+			continue
+		}
+
+		count, err := h.store.Get(key, start)
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			if _, err := h.store.Add(key, start, -count); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// errNotClearable is returned when clearing the counts of a window whose
+// synchronizer is not backed by a Datastore.
+var errNotClearable = errors.New("slidingwindow: the synchronizer does not support clearing the counts")
+
+// syncClearer is implemented by the synchronizers that are able to clear
+// the counts of the windows in the central datastore.
+type syncClearer interface {
+	clear(key string, starts ...int64) error
+}
+
+// syncFlusher is implemented by the synchronizers that are able to sync
+// the pending changes right away, regardless of the sync interval.
+type syncFlusher interface {
+	flush(makeReq MakeFunc, handleResp HandleFunc)
+}
+
 // BlockingSynchronizer does synchronization in a blocking mode and consumes
 // no extra goroutine.
 //
@@ -103,6 +180,20 @@ func (s *BlockingSynchronizer) Sync(now time.Time, makeReq MakeFunc, handleResp
 	}
 }
 
+// flush syncs the window's count to the central datastore, no matter
+// whether it's time to sync.
+func (s *BlockingSynchronizer) flush(makeReq MakeFunc, handleResp HandleFunc) {
+	resp, err := s.helper.Sync(makeReq())
+	if err != nil {
+		log.Printf("err: %v\n", err)
+	}
+	handleResp(resp)
+}
+
+func (s *BlockingSynchronizer) clear(key string, starts ...int64) error {
+	return s.helper.Clear(key, starts...)
+}
+
 // NonblockingSynchronizer does synchronization in a non-blocking mode. To achieve
 // this, it needs to spawn a goroutine to exchange data with the central datastore.
 //
@@ -186,3 +277,34 @@ func (s *NonblockingSynchronizer) Sync(now time.Time, makeReq MakeFunc, handleRe
 		}
 	}
 }
+
+// flush waits for the ongoing synchronization, if any, and then syncs the
+// window's count to the central datastore, no matter whether it's time to
+// sync. Note that flush does nothing after the synchronizer is stopped.
+func (s *NonblockingSynchronizer) flush(makeReq MakeFunc, handleResp HandleFunc) {
+	if s.helper.InProgress() {
+		select {
+		case resp := <-s.respC:
+			handleResp(resp)
+			s.helper.End()
+		case <-s.stopC:
+			return
+		}
+	}
+
+	select {
+	case s.reqC <- makeReq():
+	case <-s.stopC:
+		return
+	}
+
+	select {
+	case resp := <-s.respC:
+		handleResp(resp)
+	case <-s.stopC:
+	}
+}
+
+func (s *NonblockingSynchronizer) clear(key string, starts ...int64) error {
+	return s.helper.Clear(key, starts...)
+}
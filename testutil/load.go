@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	sw "github.com/RussellLuo/slidingwindow"
+)
+
+// loadOptions are the options of the built-in load.
+type loadOptions struct {
+	duration     time.Duration
+	pattern      string
+	rate         float64
+	burst        int
+	synchronizer string
+	seed         int64
+}
+
+func (o loadOptions) validate() error {
+	switch {
+	case o.pattern != "constant" && o.pattern != "poisson" && o.pattern != "burst":
+		return fmt.Errorf("unknown pattern %q", o.pattern)
+	case o.rate <= 0:
+		return fmt.Errorf("rate must be positive")
+	case o.pattern == "burst" && o.burst <= 0:
+		return fmt.Errorf("burst must be positive")
+	case o.synchronizer != "both" && synchronizers[o.synchronizer] == nil:
+		return fmt.Errorf("unknown synchronizer %q", o.synchronizer)
+	}
+	return nil
+}
+
+// next returns the gap between the previous arrival and the next one, and
+// the number of events of the next arrival.
+func (o loadOptions) next(rng *rand.Rand) (time.Duration, int) {
+	second := float64(time.Second)
+	switch o.pattern {
+	case "poisson":
+		return time.Duration(rng.ExpFloat64() / o.rate * second), 1
+	case "burst":
+		return time.Duration(float64(o.burst) / o.rate * second), o.burst
+	default:
+		return time.Duration(1 / o.rate * second), 1
+	}
+}
+
+func newBlockingSynchronizer(store sw.Datastore, syncInterval time.Duration) sw.Synchronizer {
+	return sw.NewBlockingSynchronizer(store, syncInterval)
+}
+
+func newNonblockingSynchronizer(store sw.Datastore, syncInterval time.Duration) sw.Synchronizer {
+	return sw.NewNonblockingSynchronizer(store, syncInterval)
+}
+
+var synchronizers = map[string]func(sw.Datastore, time.Duration) sw.Synchronizer{
+	"blocking":    newBlockingSynchronizer,
+	"nonblocking": newNonblockingSynchronizer,
+}
+
+// clock tells the time and waits, which is faked to make the load
+// deterministic in tests.
+type clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock is the clock of the wall time.
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// countingStore is a Datastore counting the round-trips to the underlying
+// datastore, each of which is one synchronization.
+type countingStore struct {
+	sw.Datastore
+	calls int64
+}
+
+func (s *countingStore) Add(key string, start, delta int64) (int64, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return s.Datastore.Add(key, start, delta)
+}
+
+func (s *countingStore) Get(key string, start int64) (int64, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return s.Datastore.Get(key, start)
+}
+
+// loadResult is the result of the load against one synchronizer type.
+type loadResult struct {
+	synchronizer string
+	start, end   time.Time
+	offered      int64
+	allowed      []time.Time // The times of the allowed events, sorted.
+	roundTrips   int64
+}
+
+// runLoad drives the load against the limiters of each synchronizer type in
+// turn, and writes the accuracy reports to w.
+func runLoad(w io.Writer) {
+	names := []string{load.synchronizer}
+	if load.synchronizer == "both" {
+		names = []string{"blocking", "nonblocking"}
+	}
+
+	fmt.Fprintf(w, "load: %s of %s arrivals at %g/s per limiter, %d limiter(s), limit %d per %s, sync every %s\n",
+		load.duration, load.pattern, load.rate, scale, limit, size, syncInterval)
+	for _, name := range names {
+		r := runScenario(name, realClock{})
+		fmt.Fprintln(w)
+		r.report(w)
+	}
+}
+
+// runScenario drives the load against scale limiters, whose synchronizers
+// are of the type named name, by the time of clk.
+func runScenario(name string, clk clock) *loadResult {
+	store := &countingStore{Datastore: newStore()}
+	// Use a distinct resource, so that the scenarios do not share counts.
+	resource := fmt.Sprintf("%s-%s-%d", resourceName, name, time.Now().UnixNano())
+	limiters := newLimiters(store, resource, synchronizers[name])
+
+	r := &loadResult{synchronizer: name, start: clk.Now()}
+	r.end = r.start.Add(load.duration)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, l := range limiters {
+		wg.Add(1)
+		go func(lim *sw.Limiter, rng *rand.Rand) {
+			defer wg.Done()
+			offered, allowed := drive(lim, rng, clk, r.start, r.end)
+
+			mu.Lock()
+			r.offered += offered
+			r.allowed = append(r.allowed, allowed...)
+			mu.Unlock()
+		}(l.lim, rand.New(rand.NewSource(load.seed+int64(i))))
+	}
+	wg.Wait()
+
+	for _, l := range limiters {
+		l.stop()
+	}
+	r.roundTrips = atomic.LoadInt64(&store.calls)
+	sort.Slice(r.allowed, func(i, j int) bool { return r.allowed[i].Before(r.allowed[j]) })
+	return r
+}
+
+// drive issues the events arriving between start and end to lim, and
+// returns the number of the events and the times of the allowed ones.
+func drive(lim *sw.Limiter, rng *rand.Rand, clk clock, start, end time.Time) (offered int64, allowed []time.Time) {
+	next := start
+	for {
+		gap, n := load.next(rng)
+		if next = next.Add(gap); !next.Before(end) {
+			return
+		}
+		clk.Sleep(next.Sub(clk.Now()))
+
+		for i := 0; i < n; i++ {
+			offered++
+			now := clk.Now()
+			if lim.AllowN(now, 1) {
+				allowed = append(allowed, now)
+			}
+		}
+	}
+}
+
+// report writes the accuracy report of r to w. Only the windows entirely
+// covered by the load are listed, since the others are not saturated.
+func (r *loadResult) report(w io.Writer) {
+	allowed := int64(len(r.allowed))
+	fmt.Fprintf(w, "== %s synchronizer ==\n", r.synchronizer)
+	fmt.Fprintf(w, "offered: %d, allowed: %d, denied: %d\n", r.offered, allowed, r.offered-allowed)
+	fmt.Fprintf(w, "sync round-trips: %d (%.1f/s)\n", r.roundTrips, float64(r.roundTrips)/r.end.Sub(r.start).Seconds())
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "WINDOW\tALLOWED\tLIMIT\tOVERSHOOT")
+	var maxOvershoot int64
+	i := 0
+	for ws := r.start.Truncate(size); ws.Before(r.end); ws = ws.Add(size) {
+		we := ws.Add(size)
+		var n int64
+		for ; i < len(r.allowed) && r.allowed[i].Before(we); i++ {
+			n++
+		}
+		if ws.Before(r.start) || we.After(r.end) {
+			continue // Partially covered.
+		}
+		overshoot := n - limit
+		if overshoot < 0 {
+			overshoot = 0
+		}
+		if overshoot > maxOvershoot {
+			maxOvershoot = overshoot
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", ws.Format("15:04:05.000"), n, limit, overshoot)
+	}
+	tw.Flush() // nolint:errcheck
+
+	sliding := maxSlidingCount(r.allowed, size)
+	fmt.Fprintf(w, "max overshoot per window: %d (%.1f%% of limit)\n", maxOvershoot, percent(maxOvershoot, limit))
+	fmt.Fprintf(w, "max allowed in any sliding window: %d (%.1f%% of limit)\n", sliding, percent(sliding, limit))
+}
+
+// maxSlidingCount returns the maximum number of times within any interval
+// of length size. The times must be sorted.
+func maxSlidingCount(times []time.Time, size time.Duration) (max int64) {
+	j := 0
+	for i, t := range times {
+		for t.Sub(times[j]) >= size {
+			j++
+		}
+		if n := int64(i - j + 1); n > max {
+			max = n
+		}
+	}
+	return
+}
+
+func percent(n, of int64) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
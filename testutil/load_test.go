@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only moves forward by Sleep.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	size, limit, syncInterval, scale, redisAddr = time.Second, 5, 250*time.Millisecond, 1, ""
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		load       loadOptions
+		offered    int64
+		allowed    int64
+		roundTrips int64
+		report     []string
+	}{
+		{
+			// Events arrive every 100ms, and the limiter syncs at most
+			// every 250ms, i.e. at 100ms, 400ms, ..., 1900ms.
+			load:       loadOptions{duration: 2 * time.Second, pattern: "constant", rate: 10},
+			offered:    19,
+			allowed:    10,
+			roundTrips: 7,
+			report: []string{
+				"offered: 19, allowed: 10, denied: 9",
+				"sync round-trips: 7 (3.5/s)",
+				"max overshoot per window: 0 (0.0% of limit)",
+			},
+		},
+		{
+			// 4 events arrive together every 400ms, i.e. at 400ms, 800ms,
+			// 1200ms and 1600ms, each of which syncs once.
+			load:       loadOptions{duration: 2 * time.Second, pattern: "burst", rate: 10, burst: 4},
+			offered:    16,
+			allowed:    8,
+			roundTrips: 4,
+			report: []string{
+				"offered: 16, allowed: 8, denied: 8",
+				"sync round-trips: 4 (2.0/s)",
+				"max allowed in any sliding window: 6 (120.0% of limit)",
+			},
+		},
+	}
+	for _, c := range cases {
+		load = c.load
+		r := runScenario("blocking", &fakeClock{now: start})
+
+		if r.offered != c.offered {
+			t.Errorf("%s: offered = %d, want: %d", c.load.pattern, r.offered, c.offered)
+		}
+		if n := int64(len(r.allowed)); n != c.allowed {
+			t.Errorf("%s: allowed = %d, want: %d", c.load.pattern, n, c.allowed)
+		}
+		if r.roundTrips != c.roundTrips {
+			t.Errorf("%s: roundTrips = %d, want: %d", c.load.pattern, r.roundTrips, c.roundTrips)
+		}
+
+		var b bytes.Buffer
+		r.report(&b)
+		for _, want := range c.report {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s: missing %q in report:\n%s", c.load.pattern, want, b.String())
+			}
+		}
+	}
+}
+
+func TestMaxSlidingCount(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var times []time.Time
+	for _, ms := range []int{0, 400, 900, 1000, 1100, 1950} {
+		times = append(times, start.Add(time.Duration(ms)*time.Millisecond))
+	}
+	// [400ms, 1400ms) has the most times.
+	if got := maxSlidingCount(times, time.Second); got != 4 {
+		t.Errorf("maxSlidingCount() = %d, want: %d", got, 4)
+	}
+}
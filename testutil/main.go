@@ -11,6 +11,8 @@ import (
 	"time"
 
 	sw "github.com/RussellLuo/slidingwindow"
+	"github.com/RussellLuo/slidingwindow/httpstore"
+	"github.com/RussellLuo/slidingwindow/redisstore"
 	"github.com/go-redis/redis"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -24,6 +26,7 @@ var (
 	scale        int
 	redisAddr    string
 	listenAddr   string
+	load         loadOptions
 
 	requestAllowed = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -35,43 +38,6 @@ var (
 	)
 )
 
-type RedisDatastore struct {
-	client redis.Cmdable
-	ttl    time.Duration
-}
-
-func NewRedisDatastore(client redis.Cmdable, ttl time.Duration) *RedisDatastore {
-	return &RedisDatastore{client: client, ttl: ttl}
-}
-
-func (d *RedisDatastore) fullKey(key string, start int64) string {
-	return fmt.Sprintf("%s@%d", key, start)
-}
-
-func (d *RedisDatastore) Add(key string, start, value int64) (int64, error) {
-	k := d.fullKey(key, start)
-	c, err := d.client.IncrBy(k, value).Result()
-	if err != nil {
-		return 0, err
-	}
-	// Ignore the possible error from EXPIRE command.
-	d.client.Expire(k, d.ttl).Result() // nolint:errcheck
-	return c, err
-}
-
-func (d *RedisDatastore) Get(key string, start int64) (int64, error) {
-	k := d.fullKey(key, start)
-	value, err := d.client.Get(k).Result()
-	if err != nil {
-		if err == redis.Nil {
-			// redis.Nil is not an error, it only indicates the key does not exist.
-			err = nil
-		}
-		return 0, err
-	}
-	return strconv.ParseInt(value, 10, 64)
-}
-
 type Limiter struct {
 	name string
 	lim  *sw.Limiter
@@ -85,8 +51,14 @@ func parseFlags() {
 		resourceFlag = flag.String("resource", "test", "The name of the resource that will be limited.")
 		syncFlag     = flag.String("sync", "200ms", "The time duration of sync interval.")
 		scaleFlag    = flag.Int("scale", 2, "The number of limiters that will work concurrently.")
-		redisFlag    = flag.String("redis", "localhost:6379", "The address of the Redis server.")
+		redisFlag    = flag.String("redis", "localhost:6379", "The address of the Redis server. With -load, an in-process store is used unless it is set explicitly.")
 		listenFlag   = flag.String("listen", "", "The listen address of the HTTP server.")
+		loadFlag     = flag.Duration("load", 0, "The duration of the built-in load generation. If positive, run the load and print an accuracy report instead of serving HTTP.")
+		patternFlag  = flag.String("pattern", "constant", "The arrival pattern of the load, i.e. constant, poisson or burst.")
+		rateFlag     = flag.Float64("rate", 50, "The average arrival rate (per second) of the load at each limiter.")
+		burstFlag    = flag.Int("burst", 10, "The number of events arriving together in the burst pattern.")
+		syncerFlag   = flag.String("synchronizer", "both", "The synchronizer type used by the load, i.e. blocking, nonblocking or both.")
+		seedFlag     = flag.Int64("seed", 1, "The random seed of the load.")
 	)
 
 	flag.Parse()
@@ -106,6 +78,25 @@ func parseFlags() {
 	resourceName = *resourceFlag
 	scale = *scaleFlag
 	redisAddr = *redisFlag
+	if *loadFlag > 0 && !isFlagSet("redis") {
+		redisAddr = ""
+	}
+
+	load = loadOptions{
+		duration:     *loadFlag,
+		pattern:      *patternFlag,
+		rate:         *rateFlag,
+		burst:        *burstFlag,
+		synchronizer: *syncerFlag,
+		seed:         *seedFlag,
+	}
+	if load.duration > 0 {
+		if err := load.validate(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	listenAddr = *listenFlag
 	if listenAddr == "" {
@@ -114,17 +105,36 @@ func parseFlags() {
 	}
 }
 
-func newLimiters() (limiters []Limiter) {
-	store := NewRedisDatastore(
+// isFlagSet reports whether the flag named name is set on the command line.
+func isFlagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
+
+// newStore creates the central datastore. An empty redisAddr means an
+// in-process store, which is only useful for the built-in load.
+func newStore() sw.Datastore {
+	if redisAddr == "" {
+		return httpstore.NewMemStore()
+	}
+	return redisstore.NewRedisDatastore(
 		redis.NewClient(&redis.Options{
 			Addr: redisAddr,
 		}),
 		2*size, // twice of size is just enough.
 	)
+}
 
+// newLimiters creates scale limiters sharing the counts of resource in store,
+// whose synchronizers are created by newSynchronizer.
+func newLimiters(store sw.Datastore, resource string, newSynchronizer func(sw.Datastore, time.Duration) sw.Synchronizer) (limiters []Limiter) {
 	for i := 0; i < scale; i++ {
 		lim, stop := sw.NewLimiter(size, limit, func() (sw.Window, sw.StopFunc) {
-			return sw.NewSyncWindow(resourceName, sw.NewBlockingSynchronizer(store, syncInterval))
+			return sw.NewSyncWindow(resource, newSynchronizer(store, syncInterval))
 		})
 		limiters = append(limiters, Limiter{
 			name: fmt.Sprintf("lim-%d", i),
@@ -141,7 +151,12 @@ func main() {
 
 	parseFlags()
 
-	limiters := newLimiters()
+	if load.duration > 0 {
+		runLoad(os.Stdout)
+		return
+	}
+
+	limiters := newLimiters(newStore(), resourceName, newBlockingSynchronizer)
 	defer func() {
 		for _, limiter := range limiters {
 			limiter.stop()
@@ -1,11 +1,15 @@
 package slidingwindow
 
 import (
+	"sync/atomic"
 	"time"
 )
 
 // LocalWindow represents a window that ignores sync behavior entirely
 // and only stores counters in memory.
+//
+// The fields of LocalWindow are accessed atomically, so that its count can
+// be changed by concurrent callers without holding the limiter's lock.
 type LocalWindow struct {
 	// The start boundary (timestamp in nanoseconds) of the window.
 	// [start, start + size)
@@ -20,20 +24,32 @@ func NewLocalWindow() (*LocalWindow, StopFunc) {
 }
 
 func (w *LocalWindow) Start() time.Time {
-	return time.Unix(0, w.start)
+	return time.Unix(0, atomic.LoadInt64(&w.start))
 }
 
 func (w *LocalWindow) Count() int64 {
-	return w.count
+	return atomic.LoadInt64(&w.count)
 }
 
 func (w *LocalWindow) AddCount(n int64) {
-	w.count += n
+	atomic.AddInt64(&w.count, n)
+}
+
+func (w *LocalWindow) tryAddCount(n, max int64) bool {
+	for {
+		count := atomic.LoadInt64(&w.count)
+		if count+n > max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&w.count, count, count+n) {
+			return true
+		}
+	}
 }
 
 func (w *LocalWindow) Reset(s time.Time, c int64) {
-	w.start = s.UnixNano()
-	w.count = c
+	atomic.StoreInt64(&w.start, s.UnixNano())
+	atomic.StoreInt64(&w.count, c)
 }
 
 func (w *LocalWindow) Sync(now time.Time) {}
@@ -80,6 +96,10 @@ type SyncWindow struct {
 	LocalWindow
 	changes int64
 
+	// The count of events added by this window itself, which is a part of
+	// the total count.
+	own int64
+
 	key    string
 	syncer Synchronizer
 }
@@ -97,6 +117,7 @@ func NewSyncWindow(key string, syncer Synchronizer) (*SyncWindow, StopFunc) {
 
 func (w *SyncWindow) AddCount(n int64) {
 	w.changes += n
+	w.own += n
 	w.LocalWindow.AddCount(n)
 }
 
@@ -107,10 +128,41 @@ func (w *SyncWindow) Reset(s time.Time, c int64) {
 	// central datastore before the reset, thus let the periodic synchronization
 	// take full charge of the accuracy of the window's count.
 	w.changes = 0
+	w.own = 0
 
 	w.LocalWindow.Reset(s, c)
 }
 
+// Migrate moves the window to the start s with the migrated count c.
+//
+// Since every window sharing the same key migrates its count, which is an
+// estimation of the total count, only the share contributed by this window
+// itself is synced to the central datastore, to avoid counting the same
+// events more than once.
+//
+// Note that if s happens to be the start of some old window other than the
+// current one, the counts already in the datastore will be added up with
+// the migrated ones, which errs on the side of limiting.
+func (w *SyncWindow) Migrate(s time.Time, c int64) {
+	share := float64(0)
+	if w.LocalWindow.count > 0 {
+		share = float64(w.own) / float64(w.LocalWindow.count)
+	}
+
+	// The central datastore already has the count of the current window.
+	pending := c
+	if s.UnixNano() == w.LocalWindow.start {
+		pending -= w.LocalWindow.count - w.changes
+		if pending < 0 {
+			pending = 0
+		}
+	}
+
+	w.Reset(s, c)
+	w.changes = int64(share * float64(pending))
+	w.own = w.changes
+}
+
 func (w *SyncWindow) makeSyncRequest() SyncRequest {
 	return SyncRequest{
 		Key:     w.key,
@@ -136,3 +188,21 @@ func (w *SyncWindow) handleSyncResponse(resp SyncResponse) {
 func (w *SyncWindow) Sync(now time.Time) {
 	w.syncer.Sync(now, w.makeSyncRequest, w.handleSyncResponse)
 }
+
+func (w *SyncWindow) pending() int64 {
+	return w.changes
+}
+
+func (w *SyncWindow) clear(starts ...int64) error {
+	c, ok := w.syncer.(syncClearer)
+	if !ok {
+		return errNotClearable
+	}
+	return c.clear(w.key, starts...)
+}
+
+func (w *SyncWindow) flush() {
+	if f, ok := w.syncer.(syncFlusher); ok {
+		f.flush(w.makeSyncRequest, w.handleSyncResponse)
+	}
+}